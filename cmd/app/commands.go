@@ -0,0 +1,243 @@
+package main
+
+import (
+	"WBTechL0/internal/cache"
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/consumer"
+	"WBTechL0/internal/db"
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/export"
+	"WBTechL0/internal/importer"
+	"WBTechL0/internal/keyring"
+	"WBTechL0/internal/money"
+	"WBTechL0/internal/reconcile"
+	"WBTechL0/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runCommand выполняет подкоманду и возвращает код выхода
+func runCommand(name string, args []string) int {
+	// Логи подкоманд идут в stderr, чтобы не смешиваться с выводом в stdout
+	sl := setupLogger(envDev, os.Stderr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch name {
+	case "export":
+		err = runExport(ctx, args, sl)
+	case "import":
+		err = runImport(ctx, args, sl)
+	case "replay":
+		err = runReplay(ctx, args, sl)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\nusage: app [export|import|replay] [flags]\n", name)
+		return 2
+	}
+	if err != nil {
+		sl.Error("Command failed", "command", name, "error", err)
+		return 1
+	}
+	return 0
+}
+
+// runExport выгружает заказы в CSV: app export [-from 2024-01-01] [-to 2024-01-31] [-customer id] [-service name] [-per order|item] [-excel] [-o file]
+func runExport(ctx context.Context, args []string, sl *slog.Logger) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	from := fs.String("from", "", "first day of date_created, YYYY-MM-DD")
+	to := fs.String("to", "", "last day of date_created (inclusive), YYYY-MM-DD")
+	customer := fs.String("customer", "", "only orders of this customer_id")
+	deliveryService := fs.String("service", "", "only orders of this delivery_service")
+	per := fs.String("per", "order", "one row per order or per item")
+	excel := fs.Bool("excel", false, "write a file Excel opens correctly (BOM, CRLF, formula escaping)")
+	out := fs.String("o", "-", "output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var filter repository.OrderFilter
+	var err error
+	if *from != "" {
+		if filter.From, err = time.Parse(time.DateOnly, *from); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *to != "" {
+		t, err := time.Parse(time.DateOnly, *to)
+		if err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+	filter.CustomerID = *customer
+	filter.DeliveryService = *deliveryService
+
+	opts := export.Options{Excel: *excel}
+	if opts.Per, err = export.ParseGranularity(*per); err != nil {
+		return err
+	}
+
+	srv, _, closeDB, err := openService(ctx, sl)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	_, err = srv.ExportOrders(ctx, w, filter, opts)
+	return err
+}
+
+// runImport загружает заказы из файла JSON Lines (можно сжатого gzip):
+// app import [-batch 500] [-checkpoint file] [-rejects file] [-restart] orders.jsonl[.gz]
+// Прерванная загрузка продолжается со строки из контрольной точки
+func runImport(ctx context.Context, args []string, sl *slog.Logger) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	batchSize := fs.Int("batch", 500, "lines saved per transaction")
+	checkpoint := fs.String("checkpoint", "", "file with the last processed line (default <file>.checkpoint)")
+	rejects := fs.String("rejects", "", "JSON Lines report of rejected lines (default <file>.rejects.jsonl)")
+	restart := fs.Bool("restart", false, "ignore the checkpoint and start from the first line")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: app import [flags] orders.jsonl[.gz]")
+	}
+
+	opts := importer.Options{Path: fs.Arg(0), BatchSize: *batchSize, Checkpoint: *checkpoint, Rejects: *rejects, Restart: *restart}
+	if opts.Checkpoint == "" {
+		opts.Checkpoint = opts.Path + ".checkpoint"
+	}
+	if opts.Rejects == "" {
+		opts.Rejects = opts.Path + ".rejects.jsonl"
+	}
+
+	srv, _, closeDB, err := openService(ctx, sl)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	sum, err := importer.Run(ctx, srv, sl, opts)
+	sl.Info("Import finished", "resumed_from", sum.ResumedFrom, "last_line", sum.LastLine,
+		"imported", sum.Imported, "skipped", sum.Skipped, "rejected", sum.Rejected, "rejects", opts.Rejects)
+	_ = json.NewEncoder(os.Stdout).Encode(sum)
+	return err
+}
+
+// runReplay перечитывает диапазон топика заказов под отдельной группой:
+// app replay -group name (-offsets 0:100-200,1:0-50 | -from 2024-05-01T00:00:00Z [-to ...])
+// Диапазоны смещений — [начало, конец) по партициям
+func runReplay(ctx context.Context, args []string, sl *slog.Logger) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	group := fs.String("group", "", "consumer group id for replay progress, must differ from the main group")
+	offsetsFlag := fs.String("offsets", "", "partition offset ranges, e.g. 0:100-200,1:0-50 (end exclusive)")
+	from := fs.String("from", "", "replay messages with timestamp at or after, RFC 3339")
+	to := fs.String("to", "", "replay messages with timestamp before, RFC 3339")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := consumer.ReplayOptions{GroupID: *group}
+	var err error
+	if *offsetsFlag != "" {
+		if opts.Offsets, err = parseOffsetRanges(*offsetsFlag); err != nil {
+			return err
+		}
+	}
+	if *from != "" {
+		if opts.From, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *to != "" {
+		if opts.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+	if (opts.Offsets == nil) == opts.From.IsZero() {
+		return errors.New("specify either -offsets or -from")
+	}
+
+	srv, cfg, closeDB, err := openService(ctx, sl)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	sl.Info("Start replay", "topic", cfg.Kafka.Topic, "group", opts.GroupID)
+	report, err := consumer.New(srv, cfg.Kafka).Replay(ctx, opts)
+	sl.Info("Replay finished", "inserted", report.Inserted, "updated", report.Updated, "skipped", report.Skipped, "rejected", report.Rejected)
+	_ = json.NewEncoder(os.Stdout).Encode(report)
+	return err
+}
+
+// parseOffsetRanges разбирает "0:100-200,1:0-50"
+func parseOffsetRanges(s string) (map[int32]consumer.OffsetRange, error) {
+	ranges := make(map[int32]consumer.OffsetRange)
+	for _, part := range strings.Split(s, ",") {
+		p, rng, ok1 := strings.Cut(strings.TrimSpace(part), ":")
+		start, end, ok2 := strings.Cut(rng, "-")
+		partition, err1 := strconv.ParseInt(p, 10, 32)
+		startOffset, err2 := strconv.ParseInt(start, 10, 64)
+		endOffset, err3 := strconv.ParseInt(end, 10, 64)
+		if !ok1 || !ok2 || errors.Join(err1, err2, err3) != nil || startOffset < 0 || endOffset <= startOffset {
+			return nil, fmt.Errorf("invalid offset range %q, expected partition:start-end", part)
+		}
+		ranges[int32(partition)] = consumer.OffsetRange{Start: startOffset, End: endOffset}
+	}
+	return ranges, nil
+}
+
+// openService загружает конфиг, подключается к бд и собирает сервис заказов
+// без запуска остальных компонентов приложения (HTTP, Kafka, фоновых задач)
+func openService(ctx context.Context, sl *slog.Logger) (*service.OrderService, *config.Config, func(), error) {
+	cfg, err := config.MustLoad()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	var kr *keyring.Keyring
+	if cfg.KeyringFile != "" {
+		if kr, err = keyring.Load(cfg.KeyringFile); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load keyring: %w", err)
+		}
+	}
+	baseCurrency, ok := money.LookupCurrency(cfg.BaseCurrency)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("unknown base currency %q", cfg.BaseCurrency)
+	}
+	reconcileMode, err := reconcile.ParseMode(cfg.Reconciliation.Mode)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	conn, err := db.ConnectToDB(ctx, cfg.Database, sl)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	repo := repository.New(conn, sl, kr)
+	return service.New(cache.New(cfg.Cache), repo, sl, baseCurrency, reconcileMode), cfg, conn.Close, nil
+}
@@ -1,18 +1,29 @@
 package main
 
 import (
+	"WBTechL0/internal/analytics"
 	"WBTechL0/internal/cache"
 	"WBTechL0/internal/config"
 	"WBTechL0/internal/consumer"
 	"WBTechL0/internal/db"
 	"WBTechL0/internal/db/repository"
 	"WBTechL0/internal/http"
+	"WBTechL0/internal/keyring"
+	"WBTechL0/internal/money"
+	"WBTechL0/internal/outbox"
+	"WBTechL0/internal/reconcile"
+	"WBTechL0/internal/redact"
+	"WBTechL0/internal/retention"
 	"WBTechL0/internal/service"
+	"WBTechL0/internal/snapshot"
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 const (
@@ -21,9 +32,17 @@ const (
 	envProd  = "prod"
 )
 
+// listenTimeout — сколько ждать подписки на изменения заказов перед загрузкой кэша
+const listenTimeout = 10 * time.Second
+
 func main() {
-	// Инициализируем логгер
-	sl := setupLogger(envDev)
+	// Подкоманды: app export ..., app import ..., app replay ...
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	// Инициализируем логгер; до загрузки конфига окружение неизвестно
+	sl := setupLogger(envDev, os.Stdout)
 	sl.Debug("Logger initialized")
 
 	// Загружаем конфиг
@@ -31,48 +50,165 @@ func main() {
 	cfg, err := config.MustLoad()
 	if err != nil {
 		sl.Error("Error in loading config:", "error", err)
+		os.Exit(1)
 	}
+	sl = setupLogger(cfg.Env, os.Stdout)
 	sl.Info("Config loaded successfully", "config", cfg)
 
 	// Подключаемся к бд
 	sl.Info("Connecting to database", "dbName", cfg.DBname)
-	conn, err := db.ConnectToDB(cfg.Database)
+	// Ожидание базы прерывается сигналом завершения
+	connectCtx, stopConnect := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	conn, err := db.ConnectToDB(connectCtx, cfg.Database, sl)
+	stopConnect()
 	if err != nil {
 		sl.Error("Failed to connect to db", "error", err)
-		os.Exit(0)
+		os.Exit(1)
 	}
 	sl.Info("Connect to database successfully")
 
 	// Создаем таблицы
 	sl.Info("Creating database tables")
-	err = db.CreateTables(conn)
+	err = db.CreateTables(conn, sl)
 	if err != nil {
 		sl.Error("Failed to create tables", "error", err)
+		os.Exit(1)
 	}
 	sl.Info("Tables created successfully")
 
+	// Создаём будущие секции orders и удаляем устаревшие
+	go db.NewPartitioner(conn, cfg.Partitioning, sl).Run(context.Background())
+
+	// Загружаем ключи шифрования персональных данных
+	var kr *keyring.Keyring
+	if cfg.KeyringFile != "" {
+		sl.Info("Loading keyring", "path", cfg.KeyringFile)
+		kr, err = keyring.Load(cfg.KeyringFile)
+		if err != nil {
+			sl.Error("Failed to load keyring", "error", err)
+			os.Exit(1)
+		}
+	} else {
+		sl.Warn("Keyring is not configured, delivery personal data is stored unencrypted")
+	}
+
 	// Инициализируем репозиторий
 	sl.Info("Initializing repository")
-	repo := repository.New(conn, sl)
+	repo := repository.New(conn, sl, kr)
+
+	// Шифруем открытые записи и перешифровываем ключи после ротации, затем
+	// приводим поисковый индекс в соответствие: имена зашифрованных записей не должны лежать в нём открыто
+	go func() {
+		if kr != nil {
+			if _, err := repo.RotateDeliveryKeys(context.Background(), cfg.RotationBatchSize); err != nil {
+				sl.Error("Failed to rotate delivery keys", "error", err)
+			}
+		}
+		n, err := repo.IndexMissingOrders(context.Background())
+		if err != nil {
+			sl.Error("Failed to build search index", "error", err)
+		}
+		if n > 0 {
+			sl.Info("Orders indexed for search", "orders", n)
+		}
+	}()
 
 	// Инициализируем кэш
 	sl.Info("Initializing cache")
-	cache1 := cache.New()
-	// Восстанавливаем кэш из бд
-	sl.Info("Restoring cache from db", "dbName", cfg.DBname)
-	c, err := cache1.RestoreCacheFromDB(repo)
+	cache1 := cache.New(cfg.Cache)
+
+	// Сбрасываем из кэша заказы, изменённые другими экземплярами приложения
+	sl.Info("Start listening for order changes", "channel", db.OrderChangesChannel, "instance", db.InstanceID)
+	listener := db.NewOrderChangeListener(conn, sl, cache1.Delete, func() {
+		sl.Info("Order cache reset")
+		cache1.Clear()
+	})
+	go listener.Run(context.Background())
+
+	// Валюта отчётности и курсы валют
+	baseCurrency, ok := money.LookupCurrency(cfg.BaseCurrency)
+	if !ok {
+		sl.Error("Unknown base currency", "currency", cfg.BaseCurrency)
+		os.Exit(1)
+	}
+	if cfg.RatesFile != "" {
+		sl.Info("Loading exchange rates", "path", cfg.RatesFile)
+		rates, err := money.LoadRates(cfg.RatesFile)
+		if err == nil {
+			err = repo.UpsertExchangeRates(context.Background(), rates)
+		}
+		if err != nil {
+			sl.Error("Failed to load exchange rates", "error", err)
+		}
+	}
+
+	reconcileMode, err := reconcile.ParseMode(cfg.Reconciliation.Mode)
 	if err != nil {
-		sl.Error("Failed to init cache", "error", err)
+		sl.Error("Invalid reconciliation config", "error", err)
+		os.Exit(1)
 	}
-	sl.Info("Cache restored successfully", "Amount of restored items", c)
 
 	// Инициализируем сервис
 	sl.Info("Initializing order service")
-	orderService := service.New(cache1, repo, sl)
+	orderService := service.New(cache1, repo, sl, baseCurrency, reconcileMode)
+
+	// Восстанавливаем кэш из снимка, а если его нет или он не подходит — прогреваем из бд.
+	// Ждём подписки слушателя изменений, чтобы не потерять изменения во время загрузки
+	select {
+	case <-listener.Subscribed():
+	case <-time.After(listenTimeout):
+		sl.Warn("Order changes listener is not subscribed yet, cache may miss concurrent changes")
+	}
+	var snapshots *snapshot.Job
+	restored := false
+	if cfg.Snapshot.Path != "" {
+		snapshots = snapshot.New(orderService, kr, cfg.Snapshot, sl)
+		// Удалённые персональные данные не должны оставаться в снимке до следующего сохранения
+		orderService.OnErase = func() {
+			if err := snapshots.Invalidate(); err != nil {
+				sl.Error("Failed to invalidate cache snapshot", "path", cfg.Snapshot.Path, "error", err)
+			}
+		}
+		sl.Info("Restoring cache from snapshot", "path", cfg.Snapshot.Path)
+		c, err := snapshots.Restore(context.Background())
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			sl.Info("Cache snapshot not found", "path", cfg.Snapshot.Path)
+		case err != nil:
+			sl.Warn("Failed to restore cache from snapshot", "path", cfg.Snapshot.Path, "error", err)
+		default:
+			sl.Info("Cache restored successfully", "Amount of restored items", c)
+			restored = true
+		}
+	}
+	// Из бд кэш прогревается в фоне, начиная с новых заказов; до конца прогрева промахи читаются из бд
+	if !restored {
+		sl.Info("Warming up cache from db", "dbName", cfg.DBname, "page", cfg.Cache.WarmupPage)
+		go func() {
+			start := time.Now()
+			if err := cache1.Warm(context.Background(), repo, cfg.Cache.WarmupPage); err != nil {
+				sl.Error("Failed to warm up cache", "loaded", cache1.Warmup().Loaded, "error", err)
+				return
+			}
+			sl.Info("Cache restored successfully", "Amount of restored items", cache1.Warmup().Loaded, "took", time.Since(start))
+		}()
+	}
 
 	// Инициализируем сервер
 	sl.Info("Initializing http server")
-	httpServer := http.New(orderService, cfg.HttpServer)
+	if !cfg.Auth.Enabled {
+		if cfg.Env == envProd {
+			sl.Error("Authentication must be enabled in prod")
+			os.Exit(1)
+		}
+		sl.Warn("Authentication is disabled, all requests are served as anonymous viewer")
+	}
+	authenticator, err := http.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		sl.Error("Failed to init authentication", "error", err)
+		os.Exit(1)
+	}
+	httpServer := http.New(orderService, cfg.HttpServer, authenticator)
 
 	// Инициализируем коснюмер
 	sl.Info("Initializing kafka consumer")
@@ -87,28 +223,65 @@ func main() {
 
 	// Запускаем консюмер в горутине
 	go func() {
-		sl.Info("Start kafka consumer", "brokers", cfg.Kafka.Brokers, "topic", cfg.Topic)
+		sl.Info("Start kafka consumer", "brokers", cfg.Kafka.Brokers, "topic", cfg.Kafka.Topic)
 		if err = kafkaConsumer.Start(context.Background()); err != nil {
-			sl.Error("Failed to start Kafka consumer", err)
+			sl.Error("Failed to start Kafka consumer", "error", err)
 		}
 	}()
+	// Запускаем перенос старых заказов в архив
+	if cfg.Retention.Enabled {
+		sl.Info("Start retention job", "max_age", cfg.Retention.MaxAge, "interval", cfg.Retention.Interval)
+		go retention.New(orderService, cfg.Retention, sl).Run(context.Background())
+	}
+
+	// Публикуем события о заказах из outbox
+	if cfg.Outbox.RelayEnabled {
+		if cfg.Outbox.Topic == cfg.Kafka.Topic {
+			sl.Error("Outbox topic must differ from the consumer topic", "topic", cfg.Outbox.Topic)
+			os.Exit(1)
+		}
+		producer, err := consumer.NewSyncProducer(cfg.Kafka)
+		if err != nil {
+			sl.Error("Failed to create kafka producer", "error", err)
+		} else {
+			sl.Info("Start outbox relay", "topic", cfg.Outbox.Topic)
+			go outbox.NewRelay(repo, producer, cfg.Outbox, cfg.ClientId, sl).Run(context.Background())
+		}
+	}
+
+	// Пересчёт отчётов
+	go analytics.New(orderService, cfg.Analytics, sl).Run(context.Background())
+
+	// Сохраняем снимок кэша
+	if snapshots != nil {
+		go snapshots.Run(context.Background())
+	}
+
 	// Ожидаем сигнал завершения
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	<-sigs
+
+	if snapshots != nil {
+		if err = snapshots.Save(); err != nil {
+			sl.Error("Failed to save cache snapshot", "path", cfg.Snapshot.Path, "error", err)
+		}
+	}
 }
 
-func setupLogger(env string) *slog.Logger {
-	var sl *slog.Logger
+func setupLogger(env string, w io.Writer) *slog.Logger {
+	var h slog.Handler
 
 	switch env {
 	case envLocal:
-		sl = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		h = slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
 	case envDev:
-		sl = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	case envProd:
-		sl = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	default:
+		// prod и неизвестные окружения — без отладочных логов
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelInfo})
 	}
 
-	return sl
+	// Персональные данные и секреты маскируются во всех логах
+	return slog.New(redact.NewHandler(h))
 }
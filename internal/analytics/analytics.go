@@ -0,0 +1,42 @@
+package analytics
+
+import (
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/service"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Refresher — периодический пересчёт представлений отчётов
+type Refresher struct {
+	srv *service.OrderService
+	cfg config.Analytics
+	sl  *slog.Logger
+}
+
+// New создаёт Refresher
+func New(srv *service.OrderService, cfg config.Analytics, sl *slog.Logger) *Refresher {
+	return &Refresher{srv: srv, cfg: cfg, sl: sl}
+}
+
+// Run пересчитывает представления сразу и затем с интервалом из конфига, пока не завершится ctx
+func (r *Refresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		if err := r.srv.RefreshAnalytics(ctx); err != nil {
+			r.sl.Error("Failed to refresh analytics", "error", err)
+		} else {
+			r.sl.Debug("Analytics refreshed", "took", time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
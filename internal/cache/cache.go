@@ -1,58 +1,148 @@
 package cache
 
 import (
-	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/config"
 	"WBTechL0/internal/models"
-	"context"
+	"math/bits"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Cache — структура для хранения кэша
+// Cache — структура для хранения кэша.
+// Заказы разложены по шардам по хэшу order_uid: у каждого шарда своя блокировка,
+// так что чтения и записи разных заказов почти не ждут друг друга
 type Cache struct {
+	shards []shard
+	mask   uint32 // Число шардов — степень двойки, номер шарда — hash & mask
+
+	negativeTTL time.Duration
+	maxMissing  int           // Предел запомненных отсутствующих UID на шард
+	loads       chan struct{} // Семафор одновременных загрузок
+
+	// Прогрев: пока он идёт, изменения заказов отмечаются номером seq в touched,
+	// чтобы заказ, прочитанный из базы раньше изменения, не затёр более новый
+	fills   atomic.Int32
+	seq     atomic.Uint64
+	cleared atomic.Uint64 // seq последнего Clear
+	warmup  warmupState
+}
+
+// shard — часть кэша под своей блокировкой
+type shard struct {
 	mu     sync.RWMutex // Для избежания гонки данных
-	orders map[string]models.Order
+	orders map[string]*models.Order
+	// missing — UID, которых нет в базе, и до какого момента это помнить
+	missing map[string]time.Time
+	// flights — загрузки из базы, которые сейчас выполняются
+	flights map[string]*flight
+	// touched — когда заказ последний раз менялся во время прогрева
+	touched map[string]uint64
 }
 
 // New — создание нового кэша
-func New() *Cache {
-	return &Cache{
-		orders: make(map[string]models.Order),
+func New(cfgCache config.Cache) *Cache {
+	n := 1
+	if cfgCache.Shards > 1 {
+		n = 1 << bits.Len(uint(cfgCache.Shards-1))
+	}
+	c := &Cache{
+		shards:      make([]shard, n),
+		mask:        uint32(n - 1),
+		negativeTTL: cfgCache.NegativeTTL,
+		maxMissing:  max(maxMissing/n, 1),
+		loads:       make(chan struct{}, max(cfgCache.MaxLoads, 1)),
+	}
+	c.warmup.progress.State = WarmupPending
+	for i := range c.shards {
+		c.shards[i].orders = make(map[string]*models.Order)
+		c.shards[i].missing = make(map[string]time.Time)
+		c.shards[i].flights = make(map[string]*flight)
+		c.shards[i].touched = make(map[string]uint64)
+	}
+	return c
+}
+
+// shard выбирает шард по FNV-1a от order_uid; хэш считается без аллокаций
+func (c *Cache) shard(orderUID string) *shard {
+	h := uint32(2166136261)
+	for i := 0; i < len(orderUID); i++ {
+		h ^= uint32(orderUID[i])
+		h *= 16777619
 	}
+	return &c.shards[h&c.mask]
 }
 
 // Set — добавляет заказ в кэш
 func (c *Cache) Set(order models.Order) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	s := c.shard(order.OrderUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	// Сохраняем заказ в кэш
-	c.orders[order.OrderUID] = order
+	s.orders[order.OrderUID] = &order
+	c.invalidate(s, order.OrderUID)
 }
 
-// Get — извлекает заказ из кэша по его UID
+// Get — извлекает заказ из кэша по его UID.
+// Заказ не копируется и общий для всех читателей: изменять его нельзя
 func (c *Cache) Get(orderUID string) (*models.Order, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	s := c.shard(orderUID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	order, found := c.orders[orderUID]
-	if !found {
-		return nil, false
-	}
-	return &order, found
+	order, found := s.orders[orderUID]
+	return order, found
+}
+
+// Delete — удаляет заказ из кэша
+func (c *Cache) Delete(orderUID string) {
+	s := c.shard(orderUID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.orders, orderUID)
+	c.invalidate(s, orderUID)
 }
 
-// RestoreCacheFromDB загружает все заказы из базы данных в кэш
-func (c *Cache) RestoreCacheFromDB(repo *repository.Repo) (int, error) {
-	orders, err := repo.GetAllOrders(context.Background())
-	if err != nil {
-		return 0, err
+// Clear — удаляет из кэша все заказы
+func (c *Cache) Clear() {
+	c.cleared.Store(c.seq.Add(1))
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.orders = make(map[string]*models.Order)
+		s.missing = make(map[string]time.Time)
+		for _, f := range s.flights {
+			f.stale = true
+		}
+		s.flights = make(map[string]*flight)
+		s.mu.Unlock()
 	}
+}
 
-	// Загружаем заказы в кэш
-	for _, order := range orders {
-		c.Set(order)
+// Len — количество заказов в кэше
+func (c *Cache) Len() int {
+	n := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.RLock()
+		n += len(s.orders)
+		s.mu.RUnlock()
 	}
+	return n
+}
 
-	// Возвращаем количество записей, восстановленных из бд и ошибку
-	return len(orders), nil
+// Orders — возвращает копию всех заказов кэша
+func (c *Cache) Orders() []models.Order {
+	orders := make([]models.Order, 0, c.Len())
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.RLock()
+		for _, order := range s.orders {
+			orders = append(orders, *order)
+		}
+		s.mu.RUnlock()
+	}
+	return orders
 }
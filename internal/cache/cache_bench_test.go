@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/models"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const benchOrders = 10_000
+
+func benchCache(shards int) (*Cache, []string) {
+	c := New(config.Cache{Shards: shards})
+	uids := make([]string, benchOrders)
+	for i := range uids {
+		uids[i] = "b563feb7b2b84b6test" + strconv.Itoa(i)
+		c.Set(benchOrder(uids[i]))
+	}
+	return c, uids
+}
+
+func benchOrder(uid string) models.Order {
+	return models.Order{
+		OrderUID:        uid,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Delivery:        models.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Email: "test@gmail.com"},
+		Payment:         models.Payment{Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817, Bank: "alpha"},
+		Items:           []models.Item{{ChrtID: 9934930, Name: "Mascaras", Price: 453, TotalPrice: 317, Brand: "Vivienne Sabo"}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+}
+
+// benchmarkMixed — чтения HTTP-обработчиков вперемешку с записями консюмера: writePercent% операций — Set
+func benchmarkMixed(b *testing.B, shards, writePercent int) {
+	c, uids := benchCache(shards)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		for pb.Next() {
+			uid := uids[r.IntN(len(uids))]
+			if r.IntN(100) < writePercent {
+				c.Set(benchOrder(uid))
+			} else if _, found := c.Get(uid); !found {
+				b.Fatal("order not found")
+			}
+		}
+	})
+}
+
+func BenchmarkCacheMixed(b *testing.B) {
+	for _, shards := range []int{1, 8, 64} {
+		for _, writes := range []int{1, 10, 50} {
+			b.Run("shards="+strconv.Itoa(shards)+"/writes="+strconv.Itoa(writes)+"%", func(b *testing.B) {
+				benchmarkMixed(b, shards, writes)
+			})
+		}
+	}
+}
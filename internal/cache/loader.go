@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"WBTechL0/internal/models"
+	"context"
+	"time"
+)
+
+// maxMissing ограничивает число запомненных отсутствующих UID: перебор случайных UID не раздует кэш
+const maxMissing = 100_000
+
+// Loader читает заказ из базы; (nil, nil) — заказа нет
+type Loader func(ctx context.Context, orderUID string) (*models.Order, error)
+
+// flight — загрузка одного заказа, результата которой ждут все запросившие его
+type flight struct {
+	done  chan struct{}
+	order *models.Order
+	err   error
+	// stale — заказ изменился во время загрузки, прочитанное могло устареть и в кэш не кладётся
+	stale bool
+}
+
+// GetOrLoad возвращает заказ из кэша, а при промахе загружает его через load и кладёт в кэш.
+// Одновременные промахи по одному UID объединяются в одну загрузку, число загрузок по разным UID
+// ограничено. Отсутствующий заказ запоминается на negativeTTL: тогда возвращается (nil, nil).
+// Ошибки загрузки не кэшируются. Как и у Get, возвращённый заказ изменять нельзя
+func (c *Cache) GetOrLoad(ctx context.Context, orderUID string, load Loader) (*models.Order, error) {
+	if order, found := c.Get(orderUID); found {
+		return order, nil
+	}
+
+	s := c.shard(orderUID)
+	s.mu.Lock()
+	if order, found := s.orders[orderUID]; found {
+		s.mu.Unlock()
+		return order, nil
+	}
+	if until, found := s.missing[orderUID]; found && time.Now().Before(until) {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	f, found := s.flights[orderUID]
+	if !found {
+		f = &flight{done: make(chan struct{})}
+		s.flights[orderUID] = f
+		// Загрузка не зависит от запроса, который её начал: её результата могут ждать другие
+		go c.load(context.WithoutCancel(ctx), s, orderUID, f, load)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return f.order, f.err
+}
+
+func (c *Cache) load(ctx context.Context, s *shard, orderUID string, f *flight, load Loader) {
+	c.loads <- struct{}{}
+	order, err := load(ctx, orderUID)
+	<-c.loads
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Устаревшая загрузка уже отцеплена invalidate, а на её месте может идти новая
+	if s.flights[orderUID] == f {
+		delete(s.flights, orderUID)
+	}
+	f.order, f.err = order, err
+	close(f.done)
+
+	if err != nil || f.stale {
+		return
+	}
+	if order != nil {
+		s.orders[orderUID] = order
+		return
+	}
+	if c.negativeTTL > 0 {
+		s.remember(orderUID, c.negativeTTL, c.maxMissing)
+	}
+}
+
+// remember запоминает отсутствующий UID на ttl; вызывается под s.mu
+func (s *shard) remember(orderUID string, ttl time.Duration, limit int) {
+	now := time.Now()
+	if len(s.missing) >= limit {
+		for uid, until := range s.missing {
+			if !now.Before(until) {
+				delete(s.missing, uid)
+			}
+		}
+		if len(s.missing) >= limit {
+			return
+		}
+	}
+	s.missing[orderUID] = now.Add(ttl)
+}
+
+// invalidate забывает отсутствие заказа, помечает его загрузку устаревшей и отцепляет её,
+// чтобы запросы после изменения начали новую загрузку, а не получили данные до изменения
+// (например, удалённые персональные данные). Если идёт прогрев, отмечает изменение; вызывается под s.mu
+func (c *Cache) invalidate(s *shard, orderUID string) {
+	delete(s.missing, orderUID)
+	if f, found := s.flights[orderUID]; found {
+		f.stale = true
+		delete(s.flights, orderUID)
+	}
+	if c.fills.Load() > 0 {
+		s.touched[orderUID] = c.seq.Add(1)
+	}
+}
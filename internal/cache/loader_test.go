@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/models"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadCoalescesMisses(t *testing.T) {
+	c := New(config.Cache{MaxLoads: 4})
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context, uid string) (*models.Order, error) {
+		calls.Add(1)
+		<-release
+		return &models.Order{OrderUID: uid}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order, err := c.GetOrLoad(context.Background(), "a", load)
+			if err != nil || order == nil || order.OrderUID != "a" {
+				t.Errorf("GetOrLoad = %v, %v", order, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("loader called %d times, want 1", n)
+	}
+	if _, found := c.Get("a"); !found {
+		t.Fatal("loaded order is not cached")
+	}
+}
+
+func TestGetOrLoadNegativeCaching(t *testing.T) {
+	c := New(config.Cache{NegativeTTL: 30 * time.Millisecond})
+	var calls atomic.Int32
+	load := func(ctx context.Context, uid string) (*models.Order, error) {
+		calls.Add(1)
+		return nil, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if order, err := c.GetOrLoad(context.Background(), "missing", load); order != nil || err != nil {
+			t.Fatalf("GetOrLoad = %v, %v", order, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("loader called %d times within TTL, want 1", n)
+	}
+
+	// Сохранение заказа забывает его отсутствие
+	c.Set(models.Order{OrderUID: "missing"})
+	if order, _ := c.GetOrLoad(context.Background(), "missing", load); order == nil {
+		t.Fatal("order set after a miss is not returned")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	c.Delete("missing")
+	c.GetOrLoad(context.Background(), "missing", load)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("loader called %d times, want 2", n)
+	}
+}
+
+func TestGetOrLoadAfterDeleteStartsFreshLoad(t *testing.T) {
+	c := New(config.Cache{MaxLoads: 2})
+	oldStarted := make(chan struct{})
+	releaseOld := make(chan struct{})
+	oldDone := make(chan struct{})
+	go func() {
+		defer close(oldDone)
+		c.GetOrLoad(context.Background(), "a", func(ctx context.Context, uid string) (*models.Order, error) {
+			close(oldStarted)
+			<-releaseOld
+			return &models.Order{OrderUID: uid, Delivery: models.Delivery{Name: "Before Erasure"}}, nil
+		})
+	}()
+	<-oldStarted
+
+	// Удаление данных во время загрузки: следующий запрос не должен получить прочитанное до него
+	c.Delete("a")
+	order, err := c.GetOrLoad(context.Background(), "a", func(ctx context.Context, uid string) (*models.Order, error) {
+		return &models.Order{OrderUID: uid, Delivery: models.Delivery{Name: models.ErasedValue}}, nil
+	})
+	if err != nil || order.Delivery.Name != models.ErasedValue {
+		t.Fatalf("GetOrLoad after Delete = %+v, %v; want a fresh load", order, err)
+	}
+
+	close(releaseOld)
+	<-oldDone
+	if cached, _ := c.Get("a"); cached == nil || cached.Delivery.Name != models.ErasedValue {
+		t.Fatalf("stale load overwrote the cache: %+v", cached)
+	}
+}
+
+func TestGetOrLoadHonoursContext(t *testing.T) {
+	c := New(config.Cache{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.GetOrLoad(ctx, "slow", func(ctx context.Context, uid string) (*models.Order, error) {
+		time.Sleep(50 * time.Millisecond)
+		return nil, nil
+	})
+	if err == nil {
+		t.Fatal("expected context error")
+	}
+}
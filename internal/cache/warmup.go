@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// Состояния прогрева кэша
+const (
+	WarmupPending = "pending" // Прогрев ещё не начался
+	WarmupRunning = "warming" // Кэш заполняется, промахи читаются из базы
+	WarmupDone    = "done"
+	WarmupFailed  = "failed" // Кэш заполнен частично, промахи по-прежнему читаются из базы
+)
+
+// WarmupProgress — ход прогрева кэша
+type WarmupProgress struct {
+	State   string    `json:"state"`
+	Source  string    `json:"source,omitempty"` // db или snapshot
+	Loaded  int       `json:"loaded"`
+	Total   int       `json:"total"` // Оценка: заказы могут добавляться во время прогрева
+	Started time.Time `json:"started"`
+	Elapsed string    `json:"elapsed"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type warmupState struct {
+	mu       sync.Mutex
+	progress WarmupProgress
+	finished time.Time
+}
+
+// Warmup возвращает ход прогрева
+func (c *Cache) Warmup() WarmupProgress {
+	w := &c.warmup
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p := w.progress
+	if p.State == WarmupPending {
+		return p
+	}
+	end := w.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	p.Elapsed = end.Sub(p.Started).Round(time.Millisecond).String()
+	return p
+}
+
+func (c *Cache) updateWarmup(fn func(p *WarmupProgress)) {
+	w := &c.warmup
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	fn(&w.progress)
+	if w.progress.State == WarmupDone || w.progress.State == WarmupFailed {
+		w.finished = time.Now()
+	}
+}
+
+// MarkWarm отмечает кэш прогретым из снимка
+func (c *Cache) MarkWarm(n int) {
+	c.updateWarmup(func(p *WarmupProgress) {
+		*p = WarmupProgress{State: WarmupDone, Source: "snapshot", Loaded: n, Total: n, Started: time.Now()}
+	})
+}
+
+// Warm заполняет кэш заказами из базы страницами по pageSize, начиная с самых новых.
+// Кэш доступен всё это время: промахи читаются из базы через GetOrLoad. Заказы, уже лежащие
+// в кэше или изменённые после чтения страницы, не перезаписываются
+func (c *Cache) Warm(ctx context.Context, repo *repository.Repo, pageSize int) error {
+	c.updateWarmup(func(p *WarmupProgress) {
+		*p = WarmupProgress{State: WarmupRunning, Source: "db", Started: time.Now()}
+	})
+	err := c.warm(ctx, repo, pageSize)
+	c.updateWarmup(func(p *WarmupProgress) {
+		if err != nil {
+			p.State, p.Error = WarmupFailed, err.Error()
+			return
+		}
+		p.State = WarmupDone
+		p.Total = max(p.Total, p.Loaded)
+	})
+	return err
+}
+
+func (c *Cache) warm(ctx context.Context, repo *repository.Repo, pageSize int) error {
+	total, err := repo.CountOrders(ctx)
+	if err != nil {
+		return err
+	}
+	c.updateWarmup(func(p *WarmupProgress) { p.Total = total })
+
+	c.fills.Add(1)
+	defer c.endFill()
+
+	var after *repository.PageCursor
+	for {
+		since := c.seq.Load()
+		orders, err := repo.GetOrdersPage(ctx, after, max(pageSize, 1))
+		if err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+
+		c.fill(orders, since)
+		c.updateWarmup(func(p *WarmupProgress) { p.Loaded += len(orders) })
+
+		last := orders[len(orders)-1]
+		after = &repository.PageCursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID}
+	}
+}
+
+// fill кладёт в кэш заказы, прочитанные из базы, когда seq был равен since:
+// пропускает заказы, которые уже есть в кэше или менялись после since
+func (c *Cache) fill(orders []models.Order, since uint64) {
+	if c.cleared.Load() > since {
+		return
+	}
+	for i := range orders {
+		uid := orders[i].OrderUID
+		s := c.shard(uid)
+		s.mu.Lock()
+		if _, found := s.orders[uid]; !found && s.touched[uid] <= since {
+			s.orders[uid] = &orders[i]
+			delete(s.missing, uid)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// endFill завершает прогрев; отметки изменений больше не нужны
+func (c *Cache) endFill() {
+	if c.fills.Add(-1) > 0 {
+		return
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.touched = make(map[string]uint64)
+		s.mu.Unlock()
+	}
+}
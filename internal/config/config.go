@@ -1,17 +1,29 @@
 package config
 
 import (
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"time"
 )
 
 type Config struct {
 	HttpServer
 	Kafka
 	Database
-	Env string
+	Auth
+	Encryption
+	Retention
+	Partitioning
+	Money
+	Reconciliation
+	Analytics
+	Outbox
+	Cache
+	Snapshot
+	Env string `yaml:"env" env:"APP_ENV" env-default:"local"` // local, dev или prod
 }
 
 type HttpServer struct {
@@ -20,17 +32,140 @@ type HttpServer struct {
 }
 
 type Kafka struct {
-	Brokers []string `yaml:"brokers" env-default:"{localhost:9093}"`
-	Topic   string   `yaml:"topic" env-default:"orders"`
-	GroupId string   `yaml:"groupId" env-default:"consumer-group"`
+	Brokers  []string `yaml:"brokers" env-default:"{localhost:9093}"`
+	Topic    string   `yaml:"topic" env-default:"orders"`
+	GroupId  string   `yaml:"groupId" env-default:"consumer-group"`
+	ClientId string   `yaml:"clientId" env-default:"wb-tech-l0"`
+	Version  string   `yaml:"version"`                 // Версия протокола Kafka, например "3.3.0"; пусто — версия sarama по умолчанию
+	Workers  int      `yaml:"workers" env-default:"8"` // Сколько сообщений обрабатывать параллельно
+	// Формат сообщений без заголовка content-type: json, protobuf или avro
+	Format      string    `yaml:"format" env-default:"json"`
+	AvroSchemas string    `yaml:"avroSchemas" env-default:"./config/avro"` // Каталог схем Avro <id>.avsc — замена реестра схем
+	TLS         KafkaTLS  `yaml:"tls"`
+	SASL        KafkaSASL `yaml:"sasl"`
+}
+
+// KafkaTLS — настройки TLS-подключения к брокерам
+type KafkaTLS struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"caFile"`   // CA для проверки сертификатов брокеров
+	CertFile           string `yaml:"certFile"` // Клиентский сертификат (mTLS)
+	KeyFile            string `yaml:"keyFile"`  // Ключ клиентского сертификата
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// KafkaSASL — настройки SASL-аутентификации
+type KafkaSASL struct {
+	Enabled   bool   `yaml:"enabled"`
+	Mechanism string `yaml:"mechanism" env-default:"PLAIN"` // PLAIN, SCRAM-SHA-256 или SCRAM-SHA-512
+	User      string `yaml:"user" env:"KAFKA_SASL_USER"`
+	Password  string `yaml:"password" env:"KAFKA_SASL_PASSWORD" redact:"full"`
 }
 
 type Database struct {
-	Host     string `yaml:"host" env-default:"localhost"`
-	Port     int    `yaml:"port" env-default:"5433"`
-	User     string `yaml:"user" env-default:"kourai"`
-	Password string `yaml:"password" env-default:"kourai123"`
-	DBname   string `yaml:"dbname" env-default:"orders"`
+	DSN             string `yaml:"dsn" env:"DATABASE_URL" redact:"full"` // Если задан, используется вместо host/port/user/password/dbname
+	Host            string `yaml:"host" env-default:"localhost"`
+	Port            int    `yaml:"port" env-default:"5433"`
+	User            string `yaml:"user" env-default:"kourai"`
+	Password        string `yaml:"password" env:"DB_PASSWORD" env-default:"kourai123" redact:"full"`
+	DBname          string `yaml:"dbname" env-default:"orders"`
+	ApplicationName string `yaml:"applicationName" env-default:"wb-tech-l0"`
+
+	SSLMode     string `yaml:"sslMode" env-default:"disable"` // disable, require, verify-ca, verify-full
+	SSLRootCert string `yaml:"sslRootCert"`
+	SSLCert     string `yaml:"sslCert"`
+	SSLKey      string `yaml:"sslKey"`
+
+	MaxConns          int32         `yaml:"maxConns" env-default:"10"`
+	MinConns          int32         `yaml:"minConns" env-default:"0"`
+	MaxConnLifetime   time.Duration `yaml:"maxConnLifetime" env-default:"1h"`
+	MaxConnIdleTime   time.Duration `yaml:"maxConnIdleTime" env-default:"30m"`
+	HealthCheckPeriod time.Duration `yaml:"healthCheckPeriod" env-default:"1m"`
+	ConnectTimeout    time.Duration `yaml:"connectTimeout" env-default:"5s"`
+	StatementTimeout  time.Duration `yaml:"statementTimeout" env-default:"30s"`
+
+	ConnectAttempts int           `yaml:"connectAttempts" env-default:"10"` // 0 — пытаться бесконечно
+	RetryBackoff    time.Duration `yaml:"retryBackoff" env-default:"500ms"` // Начальная пауза между попытками
+	RetryMaxBackoff time.Duration `yaml:"retryMaxBackoff" env-default:"10s"`
+}
+
+// Auth — настройки аутентификации HTTP-сервера
+type Auth struct {
+	Enabled   bool          `yaml:"enabled" env:"AUTH_ENABLED"` // Если выключено, все запросы анонимные с ролью viewer
+	APIKeys   []APIKey      `yaml:"apiKeys"`
+	JWKSFile  string        `yaml:"jwksFile"`                     // Локальный JWKS для проверки JWT; пусто — JWT не принимаются
+	Issuer    string        `yaml:"issuer"`                       // Ожидаемый iss, пусто — не проверяется
+	Audience  string        `yaml:"audience"`                     // Ожидаемый aud, пусто — не проверяется
+	RoleClaim string        `yaml:"roleClaim" env-default:"role"` // Claim с ролью: строка или массив строк
+	Leeway    time.Duration `yaml:"leeway" env-default:"30s"`     // Допуск расхождения часов при проверке exp/nbf
+}
+
+// APIKey — статический ключ доступа; хранится только SHA-256 от ключа
+type APIKey struct {
+	Name   string `yaml:"name"`
+	SHA256 string `yaml:"sha256"` // hex(sha256(key))
+	Role   string `yaml:"role"`   // viewer, support или admin
+}
+
+// Encryption — шифрование персональных данных доставки
+type Encryption struct {
+	KeyringFile       string `yaml:"keyringFile" env:"KEYRING_FILE"` // Пусто — данные хранятся в открытом виде
+	RotationBatchSize int    `yaml:"rotationBatchSize" env-default:"500"`
+}
+
+// Retention — перенос старых заказов в архив
+type Retention struct {
+	Enabled   bool          `yaml:"enabled" env:"RETENTION_ENABLED"`
+	MaxAge    time.Duration `yaml:"maxAge" env-default:"8760h"` // Заказы старше переносятся в архив
+	Interval  time.Duration `yaml:"interval" env-default:"24h"`
+	BatchSize int           `yaml:"batchSize" env-default:"1000"`
+}
+
+// Partitioning — обслуживание помесячных секций таблицы orders
+type Partitioning struct {
+	PremakeMonths int           `yaml:"premakeMonths" env-default:"3"` // На сколько месяцев вперёд создавать секции
+	DropAfter     time.Duration `yaml:"dropAfter"`                     // Секции старше удаляются вместе с данными; 0 — не удалять
+	Interval      time.Duration `yaml:"interval" env-default:"24h"`
+}
+
+// Money — валюта отчётности и курсы валют
+type Money struct {
+	BaseCurrency string `yaml:"baseCurrency" env-default:"RUB"`
+	RatesFile    string `yaml:"ratesFile"` // CSV с курсами "currency,valid_from,rate", загружается при старте
+}
+
+// Reconciliation — сверка сумм заказа при приёме
+type Reconciliation struct {
+	Mode string `yaml:"mode" env:"RECONCILIATION_MODE" env-default:"flag"` // reject, flag или warn
+}
+
+// Analytics — отчёты по заказам
+type Analytics struct {
+	RefreshInterval time.Duration `yaml:"refreshInterval" env-default:"15m"` // Как часто пересчитывать материализованные представления
+}
+
+// Outbox — публикация событий о сохранённых заказах
+type Outbox struct {
+	RelayEnabled bool          `yaml:"relayEnabled" env:"OUTBOX_RELAY_ENABLED" env-default:"true"`
+	Topic        string        `yaml:"topic" env-default:"order-events"` // Должен отличаться от топика, который читает консюмер
+	PollInterval time.Duration `yaml:"pollInterval" env-default:"1s"`
+	BatchSize    int           `yaml:"batchSize" env-default:"100"`
+	Retention    time.Duration `yaml:"retention" env-default:"168h"` // Сколько хранить опубликованные события; 0 — всегда
+}
+
+// Cache — кэш заказов
+type Cache struct {
+	NegativeTTL time.Duration `yaml:"negativeTTL" env-default:"30s"` // Сколько помнить, что заказа нет в базе
+	MaxLoads    int           `yaml:"maxLoads" env-default:"16"`     // Сколько промахов кэша одновременно читают базу
+	Shards      int           `yaml:"shards" env-default:"64"`       // Число шардов со своей блокировкой; округляется до степени двойки
+	WarmupPage  int           `yaml:"warmupPage" env-default:"500"`  // Сколько заказов читать из базы за раз при прогреве
+}
+
+// Snapshot — снимок кэша на диске для быстрого старта
+type Snapshot struct {
+	Path     string        `yaml:"path" env:"CACHE_SNAPSHOT_PATH" env-default:"./data/cache.snapshot"` // Пусто — снимки не делаются
+	Interval time.Duration `yaml:"interval" env-default:"5m"`
+	MaxAge   time.Duration `yaml:"maxAge" env-default:"24h"` // Снимок старше не используется; столько же хранится журнал изменений
 }
 
 func MustLoad() (*Config, error) {
@@ -58,6 +193,30 @@ func MustLoad() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = cfg.validate(); err != nil {
+		return nil, err
+	}
 	//log.Printf("Config loaded successfully: %+v", cfg)
 	return &cfg, nil
 }
+
+// validate проверяет интервалы периодических задач: time.NewTicker паникует на неположительном значении
+func (cfg *Config) validate() error {
+	intervals := []struct {
+		name    string
+		value   time.Duration
+		enabled bool
+	}{
+		{"retention.interval", cfg.Retention.Interval, cfg.Retention.Enabled},
+		{"partitioning.interval", cfg.Partitioning.Interval, true},
+		{"analytics.refreshInterval", cfg.Analytics.RefreshInterval, true},
+		{"outbox.pollInterval", cfg.Outbox.PollInterval, cfg.Outbox.RelayEnabled},
+		{"snapshot.interval", cfg.Snapshot.Interval, cfg.Snapshot.Path != ""},
+	}
+	for _, i := range intervals {
+		if i.enabled && i.value <= 0 {
+			return fmt.Errorf("config: %s must be positive, got %s", i.name, i.value)
+		}
+	}
+	return nil
+}
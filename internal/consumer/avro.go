@@ -0,0 +1,67 @@
+package consumer
+
+import (
+	"WBTechL0/internal/models"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/hamba/avro/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// avroAPI сопоставляет поля схем Avro с полями моделей по тегам json, так что имена полей в схеме
+// совпадают с JSON-форматом заказа
+var avroAPI = avro.Config{TagKey: "json"}.Freeze()
+
+var ErrUnknownSchema = errors.New("unknown avro schema")
+
+// avroRegistry — локальная замена реестра схем: схемы лежат в каталоге файлами <id>.avsc.
+// Сообщения приходят в формате Confluent: нулевой байт, 4 байта id схемы (big-endian), данные Avro
+type avroRegistry struct {
+	schemas map[uint32]avro.Schema
+}
+
+// loadAvroRegistry загружает схемы из каталога
+func loadAvroRegistry(dir string) (*avroRegistry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.avsc"))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &avroRegistry{schemas: make(map[uint32]avro.Schema, len(files))}
+	for _, file := range files {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(file), ".avsc"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("avro schema file %s: name must be <schema id>.avsc", file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := avro.Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("avro schema file %s: %w", file, err)
+		}
+		r.schemas[uint32(id)] = schema
+	}
+	return r, nil
+}
+
+// decodeOrder разбирает заказ по схеме, id которой указан в сообщении.
+// Поля схемы, которых нет в модели, пропускаются
+func (r *avroRegistry) decodeOrder(value []byte) (models.Order, error) {
+	var order models.Order
+	if len(value) < 5 || value[0] != 0 {
+		return order, errors.New("avro message is not in schema registry wire format")
+	}
+	id := binary.BigEndian.Uint32(value[1:5])
+	schema, ok := r.schemas[id]
+	if !ok {
+		return order, fmt.Errorf("%w: id %d", ErrUnknownSchema, id)
+	}
+	err := avroAPI.Unmarshal(schema, value[5:], &order)
+	return order, err
+}
@@ -0,0 +1,78 @@
+package consumer
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func loadTestAvroRegistry(t *testing.T) *avroRegistry {
+	t.Helper()
+	r, err := loadAvroRegistry("../../config/avro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// encodeAvroOrder кодирует заказ по схеме id в формате Confluent
+func encodeAvroOrder(t *testing.T, r *avroRegistry, id uint32, order any) []byte {
+	t.Helper()
+	data, err := avroAPI.Marshal(r.schemas[id], order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], id)
+	return append(header, data...)
+}
+
+func TestAvroDecodeOrderFixture(t *testing.T) {
+	r := loadTestAvroRegistry(t)
+	want := fixtureOrder()
+	data := fixture(t, "order.avro", encodeAvroOrder(t, r, 1, want))
+
+	got, err := r.decodeOrder(data)
+	if err != nil {
+		t.Fatalf("decodeOrder: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decodeOrder =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Повторное кодирование даёт те же байты, что и фикстура
+	if again := encodeAvroOrder(t, r, 1, got); !reflect.DeepEqual(again, data) {
+		t.Fatal("re-encoded order differs from the fixture")
+	}
+}
+
+func TestAvroDecodeOrderErrors(t *testing.T) {
+	r := loadTestAvroRegistry(t)
+	valid := encodeAvroOrder(t, r, 1, fixtureOrder())
+
+	unknown := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(unknown[1:5], 999)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"too short", []byte{0, 0, 0}, nil},
+		{"no magic byte", append([]byte{1}, valid[1:]...), nil},
+		{"unknown schema", unknown, ErrUnknownSchema},
+		{"truncated", valid[:len(valid)/2], nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.decodeOrder(tt.data)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
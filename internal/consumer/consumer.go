@@ -2,10 +2,10 @@ package consumer
 
 import (
 	"WBTechL0/internal/config"
-	"WBTechL0/internal/models"
+	"WBTechL0/internal/db/repository"
 	"WBTechL0/internal/service"
 	"context"
-	"encoding/json"
+	"errors"
 	"github.com/IBM/sarama"
 	"log"
 )
@@ -15,8 +15,12 @@ type Consumer struct {
 	cfgKafka config.Kafka
 }
 
+// consumerGroupHandler обрабатывает сообщения пулом воркеров, который живёт одну сессию группы
 type consumerGroupHandler struct {
-	srv *service.OrderService
+	srv      *service.OrderService
+	decoders *decoders
+	workers  int
+	pool     *workerPool
 }
 
 // New создает новый экземпляр Kafka-консюмера
@@ -29,9 +33,15 @@ func New(orderService *service.OrderService, cfgKafka config.Kafka) *Consumer {
 
 // Start запускает консюмера
 func (c *Consumer) Start(ctx context.Context) error {
-	cfg := sarama.NewConfig()
-	//config.Version = sarama.V2_0_0_0
-	cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+	cfg, err := newSaramaConfig(c.cfgKafka)
+	if err != nil {
+		return err
+	}
+
+	decoders, err := newDecoders(c.cfgKafka)
+	if err != nil {
+		return err
+	}
 
 	consumerGroup, err := sarama.NewConsumerGroup(c.cfgKafka.Brokers, c.cfgKafka.GroupId, cfg)
 	if err != nil {
@@ -39,7 +49,7 @@ func (c *Consumer) Start(ctx context.Context) error {
 	}
 	defer consumerGroup.Close()
 
-	handler := consumerGroupHandler{srv: c.srv}
+	handler := consumerGroupHandler{srv: c.srv, decoders: decoders, workers: c.cfgKafka.Workers}
 
 	for {
 		if err = consumerGroup.Consume(ctx, []string{c.cfgKafka.Topic}, &handler); err != nil {
@@ -54,25 +64,52 @@ func (c *Consumer) Start(ctx context.Context) error {
 	}
 }
 
-func (h *consumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
-func (h *consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+// Setup запускает воркеров новой сессии
+func (h *consumerGroupHandler) Setup(_ sarama.ConsumerGroupSession) error {
+	h.pool = newWorkerPool(h.workers, h.handle)
+	return nil
+}
+
+// Cleanup вызывается при ребалансе и остановке, когда все ConsumeClaim завершились:
+// дожидаемся сообщений, уже переданных воркерам, чтобы их смещения попали в последний коммит сессии
+func (h *consumerGroupHandler) Cleanup(_ sarama.ConsumerGroupSession) error {
+	h.pool.drain()
+	return nil
+}
 
 func (h *consumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	offsets := newPartitionOffsets(sess, claim.Topic(), claim.Partition())
+
 	for msg := range claim.Messages() {
-		var order models.Order
+		offsets.dispatched(msg.Offset)
 
 		// Десериализация сообщения из Kafka
-		err := json.Unmarshal(msg.Value, &order)
+		ev, err := h.decoders.decode(msg)
 		if err != nil {
-			log.Printf("Error unmarshalling message: %v", err)
+			log.Printf("Error decoding message: partition %d offset %d: %v", msg.Partition, msg.Offset, err)
+			offsets.completed(msg.Offset)
 			continue
 		}
 
-		// Обработка и сохранение ордера через сервис
-		h.srv.SaveOrder(order)
-
-		// Подтверждаем обработку сообщения
-		sess.MarkMessage(msg, "")
+		// Обработка и сохранение ордера через сервис; смещение подтверждается после обработки
+		if !h.pool.submit(sess.Context(), ev.Order.OrderUID, job{event: ev, offset: msg.Offset, offsets: offsets}) {
+			// Сессия завершается: необработанное сообщение будет прочитано заново
+			return nil
+		}
 	}
 	return nil
 }
+
+// handle обрабатывает событие: новый заказ сохраняется, новая версия заменяет сохранённую
+func (h *consumerGroupHandler) handle(ev event) {
+	switch ev.Type {
+	case EventOrderUpdated:
+		if _, err := h.srv.ReplayOrder(context.Background(), ev.Order); err != nil {
+			log.Printf("Error updating order %s from %s: %v", ev.Order.OrderUID, ev.Producer, err)
+		}
+	default:
+		if err := h.srv.SaveOrder(context.Background(), ev.Order); err != nil && !errors.Is(err, repository.ErrOrderExists) {
+			log.Printf("Error saving order %s from %s: %v", ev.Order.OrderUID, ev.Producer, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package consumer
+
+import (
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/models"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"os"
+	"strings"
+)
+
+// Форматы сообщений
+const (
+	FormatJSON     = "json"     // Envelope или models.Order в JSON
+	FormatProtobuf = "protobuf" // Order из proto/order.proto
+	FormatAvro     = "avro"     // Order по схеме из локального реестра
+)
+
+// contentTypes — значения заголовка content-type и соответствующие им форматы
+var contentTypes = map[string]string{
+	"application/json":                   FormatJSON,
+	"application/x-protobuf":             FormatProtobuf,
+	"application/protobuf":               FormatProtobuf,
+	"application/vnd.apache.avro":        FormatAvro,
+	"application/vnd.apache.avro+binary": FormatAvro,
+	"avro/binary":                        FormatAvro,
+}
+
+// Заголовки сообщений
+const (
+	headerContentType = "content-type"
+	headerEventType   = "event-type" // Тип события для бинарных форматов; по умолчанию order.created
+	headerProducer    = "producer"
+)
+
+// decoders выбирает формат сообщения по заголовку content-type, а без него — по конфигу
+type decoders struct {
+	format string // Формат сообщений без заголовка
+	avro   *avroRegistry
+}
+
+// newDecoders готовит декодеры: загружает схемы Avro, если каталог реестра существует
+func newDecoders(cfgKafka config.Kafka) (*decoders, error) {
+	d := &decoders{format: strings.ToLower(cfgKafka.Format)}
+	switch d.format {
+	case "":
+		d.format = FormatJSON
+	case FormatJSON, FormatProtobuf, FormatAvro:
+	default:
+		return nil, fmt.Errorf("unknown kafka message format %q", cfgKafka.Format)
+	}
+
+	if cfgKafka.AvroSchemas != "" {
+		if _, err := os.Stat(cfgKafka.AvroSchemas); err == nil {
+			if d.avro, err = loadAvroRegistry(cfgKafka.AvroSchemas); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if d.format == FormatAvro && d.avro == nil {
+		return nil, errors.New("kafka message format is avro, but the avro schema directory is missing")
+	}
+	return d, nil
+}
+
+// decode разбирает сообщение в событие о заказе
+func (d *decoders) decode(msg *sarama.ConsumerMessage) (event, error) {
+	format := d.format
+	if ct := header(msg, headerContentType); ct != "" {
+		mediaType, _, _ := strings.Cut(ct, ";")
+		f, ok := contentTypes[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !ok {
+			return event{}, fmt.Errorf("unsupported content type %q", ct)
+		}
+		format = f
+	}
+
+	var order models.Order
+	var err error
+	switch format {
+	case FormatJSON:
+		return decodeEvent(msg.Value)
+	case FormatProtobuf:
+		order, err = decodeProtoOrder(msg.Value)
+	case FormatAvro:
+		if d.avro == nil {
+			return event{}, errors.New("avro message received, but no avro schemas are configured")
+		}
+		order, err = d.avro.decodeOrder(msg.Value)
+	}
+	if err != nil {
+		return event{}, fmt.Errorf("%s: %w", format, err)
+	}
+
+	// В бинарных форматах метаданные события передаются заголовками, а эволюция схемы —
+	// средствами самого формата (номера полей Protobuf, id схем Avro)
+	ev := event{Type: header(msg, headerEventType), Order: order, Producer: header(msg, headerProducer), Timestamp: msg.Timestamp, Version: CurrentSchemaVersion}
+	switch ev.Type {
+	case "":
+		ev.Type = EventOrderCreated
+	case EventOrderCreated, EventOrderUpdated:
+	default:
+		return event{}, fmt.Errorf("%w: %q", ErrUnknownEventType, ev.Type)
+	}
+	return ev, nil
+}
+
+// header возвращает значение заголовка сообщения без учёта регистра имени
+func header(msg *sarama.ConsumerMessage, name string) string {
+	for _, h := range msg.Headers {
+		if h != nil && strings.EqualFold(string(h.Key), name) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
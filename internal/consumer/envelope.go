@@ -0,0 +1,114 @@
+package consumer
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Типы событий о заказах — те же, что сервис публикует из outbox
+const (
+	EventOrderCreated = repository.EventOrderCreated
+	EventOrderUpdated = repository.EventOrderUpdated
+)
+
+// CurrentSchemaVersion — версия формата payload, соответствующая models.Order.
+// При несовместимом изменении формата версия увеличивается, а в upcasters добавляется
+// преобразование из прежней версии, чтобы сообщения старых продюсеров продолжали приниматься
+const CurrentSchemaVersion = 1
+
+// Envelope — обёртка сообщения о заказе
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	EventType     string          `json:"event_type"`
+	Producer      string          `json:"producer"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Upcaster переводит payload версии N в версию N+1
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+// upcasters[N] — преобразование payload из версии N в N+1
+var upcasters = map[int]Upcaster{}
+
+var (
+	ErrUnsupportedSchema = errors.New("unsupported schema version")
+	ErrUnknownEventType  = errors.New("unknown event type")
+)
+
+// event — разобранное сообщение
+type event struct {
+	Type      string
+	Order     models.Order
+	Producer  string
+	Timestamp time.Time
+	Version   int // Версия, в которой сообщение пришло
+}
+
+// decodeEvent разбирает значение сообщения: обёртку Envelope или, для продюсеров без обёртки,
+// заказ в формате версии 1, который считается событием order.created
+func decodeEvent(value []byte) (event, error) {
+	var probe struct {
+		SchemaVersion *int            `json:"schema_version"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(value, &probe); err != nil {
+		return event{}, err
+	}
+	if probe.SchemaVersion == nil || probe.Payload == nil {
+		var order models.Order
+		if err := json.Unmarshal(value, &order); err != nil {
+			return event{}, err
+		}
+		return event{Type: EventOrderCreated, Order: order, Version: 1}, nil
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(value, &env); err != nil {
+		return event{}, err
+	}
+	switch env.EventType {
+	case "":
+		env.EventType = EventOrderCreated
+	case EventOrderCreated, EventOrderUpdated:
+	default:
+		return event{}, fmt.Errorf("%w: %q", ErrUnknownEventType, env.EventType)
+	}
+
+	payload, err := upcast(env.Payload, env.SchemaVersion)
+	if err != nil {
+		return event{}, err
+	}
+	var order models.Order
+	if err = json.Unmarshal(payload, &order); err != nil {
+		return event{}, err
+	}
+	return event{Type: env.EventType, Order: order, Producer: env.Producer, Timestamp: env.Timestamp, Version: env.SchemaVersion}, nil
+}
+
+// upcast последовательно приводит payload версии version к CurrentSchemaVersion
+func upcast(payload json.RawMessage, version int) (json.RawMessage, error) {
+	return upcastTo(payload, version, CurrentSchemaVersion, upcasters)
+}
+
+// upcastTo приводит payload версии version к версии current преобразованиями из ups
+func upcastTo(payload json.RawMessage, version, current int, ups map[int]Upcaster) (json.RawMessage, error) {
+	if version < 1 || version > current {
+		return nil, fmt.Errorf("%w: %d (supported 1..%d)", ErrUnsupportedSchema, version, current)
+	}
+	for v := version; v < current; v++ {
+		up, ok := ups[v]
+		if !ok {
+			return nil, fmt.Errorf("%w: no upcaster from version %d", ErrUnsupportedSchema, v)
+		}
+		var err error
+		if payload, err = up(payload); err != nil {
+			return nil, fmt.Errorf("upcast from version %d: %w", v, err)
+		}
+	}
+	return payload, nil
+}
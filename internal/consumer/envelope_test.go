@@ -0,0 +1,129 @@
+package consumer
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envelopeJSON(t *testing.T, version int, eventType string, payload any) []byte {
+	t.Helper()
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(Envelope{
+		SchemaVersion: version, EventType: eventType, Producer: "test",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Payload: raw,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeEvent(t *testing.T) {
+	order := fixtureOrder()
+	bare, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		value    []byte
+		wantType string
+		wantErr  error
+	}{
+		{name: "legacy bare payload", value: bare, wantType: EventOrderCreated},
+		{name: "created", value: envelopeJSON(t, 1, EventOrderCreated, order), wantType: EventOrderCreated},
+		{name: "updated", value: envelopeJSON(t, 1, EventOrderUpdated, order), wantType: EventOrderUpdated},
+		{name: "no event type", value: envelopeJSON(t, 1, "", order), wantType: EventOrderCreated},
+		{name: "unknown event type", value: envelopeJSON(t, 1, "order.deleted", order), wantErr: ErrUnknownEventType},
+		{name: "unknown version", value: envelopeJSON(t, 0, EventOrderCreated, order), wantErr: ErrUnsupportedSchema},
+		{name: "newer version", value: envelopeJSON(t, CurrentSchemaVersion+1, EventOrderCreated, order), wantErr: ErrUnsupportedSchema},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := decodeEvent(tt.value)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("decodeEvent error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeEvent: %v", err)
+			}
+			if ev.Type != tt.wantType || !reflect.DeepEqual(ev.Order, order) {
+				t.Fatalf("decodeEvent = %s %+v, want %s %+v", ev.Type, ev.Order, tt.wantType, order)
+			}
+		})
+	}
+}
+
+// testUpcasters — цепочка версий 1 → 2 → 3: в версии 2 поле order_uid переименовано в uid,
+// в версии 3 — обратно, так что результат разбирается как models.Order
+var testUpcasters = map[int]Upcaster{
+	1: renameField("order_uid", "uid"),
+	2: renameField("uid", "order_uid"),
+}
+
+func renameField(from, to string) Upcaster {
+	return func(payload json.RawMessage) (json.RawMessage, error) {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &m); err != nil {
+			return nil, err
+		}
+		v, ok := m[from]
+		if !ok {
+			return nil, errors.New("no field " + from)
+		}
+		delete(m, from)
+		m[to] = v
+		return json.Marshal(m)
+	}
+}
+
+func TestUpcast(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		version int
+		ups     map[int]Upcaster
+		want    string // Поле, в котором ожидается UID
+		wantErr error
+	}{
+		{name: "current version", payload: `{"order_uid":"a"}`, version: 3, ups: testUpcasters, want: "order_uid"},
+		{name: "one step", payload: `{"uid":"a"}`, version: 2, ups: testUpcasters, want: "order_uid"},
+		{name: "two steps", payload: `{"order_uid":"a"}`, version: 1, ups: testUpcasters, want: "order_uid"},
+		{name: "missing upcaster", payload: `{"order_uid":"a"}`, version: 1, ups: map[int]Upcaster{2: testUpcasters[2]}, wantErr: ErrUnsupportedSchema},
+		{name: "unknown version", payload: `{"order_uid":"a"}`, version: 0, ups: testUpcasters, wantErr: ErrUnsupportedSchema},
+		{name: "newer version", payload: `{"order_uid":"a"}`, version: 4, ups: testUpcasters, wantErr: ErrUnsupportedSchema},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := upcastTo(json.RawMessage(tt.payload), tt.version, 3, tt.ups)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("upcastTo error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("upcastTo: %v", err)
+			}
+			if want := `"` + tt.want + `":"a"`; !strings.Contains(string(got), want) {
+				t.Fatalf("upcastTo = %s, want %s", got, want)
+			}
+		})
+	}
+
+	// Ошибка преобразования не маскируется под неподдерживаемую версию
+	if _, err := upcastTo(json.RawMessage(`{"uid":"a"}`), 1, 3, testUpcasters); err == nil || errors.Is(err, ErrUnsupportedSchema) {
+		t.Fatalf("upcastTo with a failing upcaster = %v, want the upcaster error", err)
+	}
+}
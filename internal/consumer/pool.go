@@ -0,0 +1,105 @@
+package consumer
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"hash/fnv"
+	"sync"
+)
+
+// job — событие из сообщения, переданное воркеру
+type job struct {
+	event   event
+	offset  int64
+	offsets *partitionOffsets
+}
+
+// workerPool обрабатывает заказы параллельно. Заказы с одним order_uid попадают к одному воркеру
+// и обрабатываются в порядке поступления
+type workerPool struct {
+	queues []chan job
+	wg     sync.WaitGroup
+}
+
+// queueSize — сколько сообщений может ждать каждого воркера
+const queueSize = 64
+
+func newWorkerPool(n int, handle func(event)) *workerPool {
+	if n <= 0 {
+		n = 1
+	}
+	p := &workerPool{queues: make([]chan job, n)}
+	for i := range p.queues {
+		q := make(chan job, queueSize)
+		p.queues[i] = q
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for j := range q {
+				handle(j.event)
+				j.offsets.completed(j.offset)
+			}
+		}()
+	}
+	return p
+}
+
+// submit передаёт заказ воркеру по ключу; false — ctx завершился раньше, чем воркер освободился
+func (p *workerPool) submit(ctx context.Context, key string, j job) bool {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	select {
+	case p.queues[h.Sum32()%uint32(len(p.queues))] <- j:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// drain дожидается обработки всех переданных заказов и останавливает воркеров
+func (p *workerPool) drain() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+}
+
+// partitionOffsets отслеживает обработанные сообщения партиции. Сообщения завершаются не по порядку,
+// поэтому подтверждается только смещение, до которого обработано всё
+type partitionOffsets struct {
+	sess      sarama.ConsumerGroupSession
+	topic     string
+	partition int32
+
+	mu       sync.Mutex
+	inFlight []int64 // Переданные в работу смещения по возрастанию
+	done     map[int64]bool
+}
+
+func newPartitionOffsets(sess sarama.ConsumerGroupSession, topic string, partition int32) *partitionOffsets {
+	return &partitionOffsets{sess: sess, topic: topic, partition: partition, done: make(map[int64]bool)}
+}
+
+// dispatched регистрирует сообщение до передачи воркеру
+func (o *partitionOffsets) dispatched(offset int64) {
+	o.mu.Lock()
+	o.inFlight = append(o.inFlight, offset)
+	o.mu.Unlock()
+}
+
+// completed отмечает сообщение обработанным и подтверждает самое младшее смещение, до которого обработано всё
+func (o *partitionOffsets) completed(offset int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.done[offset] = true
+	var next int64 = -1
+	for len(o.inFlight) > 0 && o.done[o.inFlight[0]] {
+		next = o.inFlight[0] + 1
+		delete(o.done, o.inFlight[0])
+		o.inFlight = o.inFlight[1:]
+	}
+	if next >= 0 {
+		o.sess.MarkOffset(o.topic, o.partition, next, "")
+	}
+}
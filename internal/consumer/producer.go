@@ -0,0 +1,28 @@
+package consumer
+
+import (
+	"WBTechL0/internal/config"
+	"github.com/IBM/sarama"
+)
+
+// NewSyncProducer создаёт продюсер с теми же настройками подключения, что и у консюмера.
+// Продюсер идемпотентный и ждёт подтверждения от всех реплик, так что повторная отправка
+// после сбоя не порождает дублей внутри сессии и сообщения одного ключа не переставляются
+func NewSyncProducer(cfgKafka config.Kafka) (sarama.SyncProducer, error) {
+	cfg, err := newSaramaConfig(cfgKafka)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Version.IsAtLeast(sarama.V0_11_0_0) {
+		cfg.Producer.Idempotent = true
+		cfg.Net.MaxOpenRequests = 1
+	}
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Partitioner = sarama.NewHashPartitioner
+
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return sarama.NewSyncProducer(cfgKafka.Brokers, cfg)
+}
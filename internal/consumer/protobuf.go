@@ -0,0 +1,109 @@
+package consumer
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=module=WBTechL0 ../../proto/order.proto
+
+import (
+	"WBTechL0/internal/consumer/orderpb"
+	"WBTechL0/internal/models"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// decodeProtoOrder разбирает сообщение Order из proto/order.proto.
+// Неизвестные поля пропускаются, так что продюсер может добавлять новые
+func decodeProtoOrder(b []byte) (models.Order, error) {
+	var msg orderpb.Order
+	if err := proto.Unmarshal(b, &msg); err != nil {
+		return models.Order{}, err
+	}
+	if err := checkProtoWireTypes(msg.ProtoReflect()); err != nil {
+		return models.Order{}, err
+	}
+
+	d, p := msg.GetDelivery(), msg.GetPayment()
+	order := models.Order{
+		OrderUID:    msg.GetOrderUid(),
+		TrackNumber: msg.GetTrackNumber(),
+		Entry:       msg.GetEntry(),
+		Delivery: models.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		},
+		Payment: models.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       int(p.GetAmount()),
+			PaymentDT:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: int(p.GetDeliveryCost()),
+			GoodsTotal:   int(p.GetGoodsTotal()),
+			CustomFee:    int(p.GetCustomFee()),
+		},
+		Locale:            msg.GetLocale(),
+		InternalSignature: msg.GetInternalSignature(),
+		CustomerID:        msg.GetCustomerId(),
+		DeliveryService:   msg.GetDeliveryService(),
+		Shardkey:          msg.GetShardkey(),
+		SmID:              int(msg.GetSmId()),
+		OofShard:          msg.GetOofShard(),
+	}
+	if msg.GetDateCreated() != nil {
+		order.DateCreated = msg.GetDateCreated().AsTime()
+	}
+	for _, item := range msg.GetItems() {
+		order.Items = append(order.Items, models.Item{
+			ChrtID:      int(item.GetChrtId()),
+			TrackNumber: item.GetTrackNumber(),
+			Price:       int(item.GetPrice()),
+			Rid:         item.GetRid(),
+			Name:        item.GetName(),
+			Sale:        int(item.GetSale()),
+			Size:        item.GetSize(),
+			TotalPrice:  int(item.GetTotalPrice()),
+			NmID:        int(item.GetNmId()),
+			Brand:       item.GetBrand(),
+			Status:      int(item.GetStatus()),
+		})
+	}
+	return order, nil
+}
+
+// checkProtoWireTypes отклоняет сообщение, в котором известное поле пришло с другим типом провода:
+// proto.Unmarshal молча откладывает такие поля в неизвестные, и заказ сохранился бы без них
+func checkProtoWireTypes(m protoreflect.Message) error {
+	fields := m.Descriptor().Fields()
+	for b := m.GetUnknown(); len(b) > 0; {
+		num, typ, n := protowire.ConsumeField(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if fd := fields.ByNumber(num); fd != nil {
+			return fmt.Errorf("protobuf field %s.%s: unexpected wire type %d", m.Descriptor().Name(), fd.Name(), typ)
+		}
+		b = b[n:]
+	}
+
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Message() == nil:
+		case fd.IsList():
+			for i := 0; i < v.List().Len() && err == nil; i++ {
+				err = checkProtoWireTypes(v.List().Get(i).Message())
+			}
+		default:
+			err = checkProtoWireTypes(v.Message())
+		}
+		return err == nil
+	})
+	return err
+}
@@ -0,0 +1,183 @@
+package consumer
+
+import (
+	"WBTechL0/internal/consumer/orderpb"
+	"WBTechL0/internal/models"
+	"flag"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// update перезаписывает закодированные фикстуры в testdata: go test ./internal/consumer -update
+var update = flag.Bool("update", false, "rewrite encoded fixtures in testdata")
+
+// fixtureOrder — заказ, закодированный в testdata/order.pb и testdata/order.avro
+func fixtureOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+// fixture читает закодированную фикстуру или, с флагом -update, перезаписывает её значением encoded
+func fixture(t *testing.T, name string, encoded []byte) []byte {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, encoded, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func encodeProtoOrder(t *testing.T, o models.Order) []byte {
+	t.Helper()
+	msg := &orderpb.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &orderpb.Delivery{
+			Name: o.Delivery.Name, Phone: o.Delivery.Phone, Zip: o.Delivery.Zip, City: o.Delivery.City,
+			Address: o.Delivery.Address, Region: o.Delivery.Region, Email: o.Delivery.Email,
+		},
+		Payment: &orderpb.Payment{
+			Transaction: o.Payment.Transaction, RequestId: o.Payment.RequestID, Currency: o.Payment.Currency,
+			Provider: o.Payment.Provider, Amount: int64(o.Payment.Amount), PaymentDt: o.Payment.PaymentDT,
+			Bank: o.Payment.Bank, DeliveryCost: int64(o.Payment.DeliveryCost), GoodsTotal: int64(o.Payment.GoodsTotal),
+			CustomFee: int64(o.Payment.CustomFee),
+		},
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.Shardkey,
+		SmId:              int32(o.SmID),
+		DateCreated:       timestamppb.New(o.DateCreated),
+		OofShard:          o.OofShard,
+	}
+	for _, i := range o.Items {
+		msg.Items = append(msg.Items, &orderpb.Item{
+			ChrtId: int64(i.ChrtID), TrackNumber: i.TrackNumber, Price: int64(i.Price), Rid: i.Rid, Name: i.Name,
+			Sale: int32(i.Sale), Size: i.Size, TotalPrice: int64(i.TotalPrice), NmId: int64(i.NmID), Brand: i.Brand,
+			Status: int32(i.Status),
+		})
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeProtoOrderFixture(t *testing.T) {
+	want := fixtureOrder()
+	data := fixture(t, "order.pb", encodeProtoOrder(t, want))
+
+	got, err := decodeProtoOrder(data)
+	if err != nil {
+		t.Fatalf("decodeProtoOrder: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decodeProtoOrder =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDecodeProtoOrderRoundTrip(t *testing.T) {
+	want := fixtureOrder()
+	want.Payment.Amount = -1
+	want.Items = append(want.Items, models.Item{ChrtID: 1 << 40, Sale: -5})
+	want.DateCreated = time.Date(2024, 2, 29, 23, 59, 59, 123456789, time.UTC)
+
+	got, err := decodeProtoOrder(encodeProtoOrder(t, want))
+	if err != nil {
+		t.Fatalf("decodeProtoOrder: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decodeProtoOrder =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDecodeProtoOrderWireTypes(t *testing.T) {
+	// Вложенное сообщение из одного поля
+	nested := func(num protowire.Number, typ protowire.Type, v uint64) []byte {
+		b := protowire.AppendTag(nil, num, typ)
+		return protowire.AppendVarint(b, v)
+	}
+	bytesField := func(num protowire.Number, v []byte) []byte {
+		b := protowire.AppendTag(nil, num, protowire.BytesType)
+		return protowire.AppendBytes(b, v)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"valid", bytesField(1, []byte("uid")), false},
+		{"unknown field is skipped", append(bytesField(1, []byte("uid")), nested(100, protowire.VarintType, 7)...), false},
+		{"string as varint", nested(1, protowire.VarintType, 42), true},
+		{"int32 as bytes", bytesField(12, []byte("99")), true},
+		{"nested int64 as bytes", bytesField(5, bytesField(5, []byte{1})), true},
+		{"repeated item with fixed64", bytesField(6, protowire.AppendFixed64(protowire.AppendTag(nil, 3, protowire.Fixed64Type), 1)), true},
+		{"truncated", []byte{0x0a, 0x05, 'a'}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeProtoOrder(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeProtoOrder error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package consumer
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OffsetRange — диапазон смещений партиции [Start, End)
+type OffsetRange struct {
+	Start int64
+	End   int64
+}
+
+// ReplayOptions — что перечитывать. Задаются либо Offsets, либо окно From–To по времени сообщений
+type ReplayOptions struct {
+	// GroupID — отдельная группа, под которой сохраняется прогресс перечитывания.
+	// Прерванное перечитывание с той же группой продолжается с места остановки;
+	// для другого диапазона нужна новая группа
+	GroupID string
+	Offsets map[int32]OffsetRange // Партиция → диапазон смещений
+	From    time.Time             // Начало окна (включительно)
+	To      time.Time             // Конец окна (не включая); нулевое — до последнего сообщения
+}
+
+// ReplayReport — итог перечитывания
+type ReplayReport struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`  // Заказ уже сохранён в том же виде
+	Rejected int `json:"rejected"` // Сообщение не разобралось или заказ не прошёл проверки
+}
+
+// Replay перечитывает диапазон топика и заново принимает заказы через сервис. Повторный приём идемпотентен:
+// новые заказы добавляются, изменившиеся заменяются, совпадающие пропускаются.
+// Партиции обрабатываются параллельно, сообщения внутри партиции — по порядку
+func (c *Consumer) Replay(ctx context.Context, opts ReplayOptions) (ReplayReport, error) {
+	var report ReplayReport
+	if opts.GroupID == "" {
+		return report, errors.New("replay requires a group id")
+	}
+	if opts.GroupID == c.cfgKafka.GroupId {
+		return report, errors.New("replay group id must differ from the consumer group id")
+	}
+	if len(opts.Offsets) == 0 && opts.From.IsZero() {
+		return report, errors.New("replay requires partition offsets or a start time")
+	}
+
+	cfg, err := newSaramaConfig(c.cfgKafka)
+	if err != nil {
+		return report, err
+	}
+	cfg.Consumer.Return.Errors = true
+
+	decoders, err := newDecoders(c.cfgKafka)
+	if err != nil {
+		return report, err
+	}
+
+	client, err := sarama.NewClient(c.cfgKafka.Brokers, cfg)
+	if err != nil {
+		return report, err
+	}
+	defer client.Close()
+
+	ranges, err := c.replayRanges(client, opts)
+	if err != nil {
+		return report, err
+	}
+
+	offsets, err := sarama.NewOffsetManagerFromClient(opts.GroupID, client)
+	if err != nil {
+		return report, err
+	}
+	// Close дожидается сохранения отмеченных смещений
+	defer offsets.Close()
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return report, err
+	}
+	defer consumer.Close()
+
+	// Ошибка в одной партиции останавливает остальные
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(chan error, len(ranges))
+	for partition, rng := range ranges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := partitionReplay{srv: c.srv, decoders: decoders, topic: c.cfgKafka.Topic, partition: partition, rng: rng, idle: replayIdleTimeout}
+			partial, err := r.run(runCtx, consumer, offsets)
+
+			mu.Lock()
+			report.Inserted += partial.Inserted
+			report.Updated += partial.Updated
+			report.Skipped += partial.Skipped
+			report.Rejected += partial.Rejected
+			mu.Unlock()
+
+			if err != nil {
+				errs <- fmt.Errorf("partition %d: %w", partition, err)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var failed []error
+	for err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return report, ctx.Err()
+	}
+	return report, errors.Join(failed...)
+}
+
+// replayRanges переводит параметры перечитывания в диапазоны смещений по партициям,
+// ограничивая их сообщениями, которые есть в топике
+func (c *Consumer) replayRanges(client sarama.Client, opts ReplayOptions) (map[int32]OffsetRange, error) {
+	topic := c.cfgKafka.Topic
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make(map[int32]OffsetRange)
+	for _, p := range partitions {
+		oldest, err := client.GetOffset(topic, p, sarama.OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		newest, err := client.GetOffset(topic, p, sarama.OffsetNewest)
+		if err != nil {
+			return nil, err
+		}
+
+		var rng OffsetRange
+		if len(opts.Offsets) > 0 {
+			var ok bool
+			if rng, ok = opts.Offsets[p]; !ok {
+				continue
+			}
+		} else {
+			// Смещение первого сообщения не раньше момента; -1 — таких сообщений нет
+			if rng.Start, err = client.GetOffset(topic, p, opts.From.UnixMilli()); err != nil {
+				return nil, err
+			}
+			if rng.Start < 0 {
+				continue
+			}
+			rng.End = newest
+			if !opts.To.IsZero() {
+				end, err := client.GetOffset(topic, p, opts.To.UnixMilli())
+				if err != nil {
+					return nil, err
+				}
+				if end >= 0 {
+					rng.End = end
+				}
+			}
+		}
+
+		rng.Start = max(rng.Start, oldest)
+		rng.End = min(rng.End, newest)
+		if rng.Start < rng.End {
+			ranges[p] = rng
+		}
+	}
+
+	for p := range opts.Offsets {
+		if !slices.Contains(partitions, p) {
+			return nil, fmt.Errorf("topic %s has no partition %d", topic, p)
+		}
+	}
+	return ranges, nil
+}
+
+// partitionReplay — перечитывание одной партиции
+type partitionReplay struct {
+	srv       *service.OrderService
+	decoders  *decoders
+	topic     string
+	partition int32
+	rng       OffsetRange
+	idle      time.Duration // Сколько ждать сообщений, прежде чем проверить, не кончилась ли партиция
+}
+
+// replayIdleTimeout — пауза в сообщениях, после которой партиция проверяется на конец диапазона
+const replayIdleTimeout = 5 * time.Second
+
+func (r partitionReplay) run(ctx context.Context, consumer sarama.Consumer, offsets sarama.OffsetManager) (ReplayReport, error) {
+	var report ReplayReport
+
+	pom, err := offsets.ManagePartition(r.topic, r.partition)
+	if err != nil {
+		return report, err
+	}
+	defer pom.AsyncClose()
+
+	// Продолжаем с сохранённого прогресса группы
+	start := r.rng.Start
+	if next, _ := pom.NextOffset(); next > start {
+		start = next
+	}
+	if start >= r.rng.End {
+		return report, nil
+	}
+
+	pc, err := consumer.ConsumePartition(r.topic, r.partition, start)
+	if err != nil {
+		return report, err
+	}
+	defer pc.Close()
+
+	return r.consume(ctx, pc, pom)
+}
+
+// consume принимает сообщения до конца диапазона. Сообщения с последних смещений могут не прийти вовсе:
+// их удалила компактизация или это маркеры транзакций. Поэтому диапазон дочитан, если пришло сообщение
+// за его концом или если сообщений нет дольше r.idle, а верхняя граница партиции не дальше конца диапазона
+func (r partitionReplay) consume(ctx context.Context, pc sarama.PartitionConsumer, pom sarama.PartitionOffsetManager) (ReplayReport, error) {
+	var report ReplayReport
+	idle := time.NewTimer(r.idle)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case err := <-pc.Errors():
+			return report, err
+		case msg := <-pc.Messages():
+			if msg.Offset >= r.rng.End {
+				return report, nil
+			}
+			if err := r.handle(ctx, msg, &report); err != nil {
+				return report, err
+			}
+			pom.MarkOffset(msg.Offset+1, "")
+			if msg.Offset >= r.rng.End-1 {
+				return report, nil
+			}
+			idle.Reset(r.idle)
+		case <-idle.C:
+			// Нулевая граница — ответа брокера ещё не было
+			if hwm := pc.HighWaterMarkOffset(); hwm > 0 && hwm <= r.rng.End {
+				log.Printf("Replay: partition %d has no more messages up to offset %d", r.partition, r.rng.End)
+				return report, nil
+			}
+			idle.Reset(r.idle)
+		}
+	}
+}
+
+// handle принимает заказ из сообщения. Ошибка возвращается, только если сообщение нужно перечитать позже
+func (r partitionReplay) handle(ctx context.Context, msg *sarama.ConsumerMessage, report *ReplayReport) error {
+	ev, err := r.decoders.decode(msg)
+	if err != nil {
+		log.Printf("Replay: partition %d offset %d rejected: %v", msg.Partition, msg.Offset, err)
+		report.Rejected++
+		return nil
+	}
+	order := ev.Order
+
+	// Перечитывание идемпотентно для любого типа события
+	res, err := r.srv.ReplayOrder(ctx, order)
+	switch {
+	case errors.Is(err, service.ErrInvalidOrder), errors.Is(err, service.ErrInconsistentTotals):
+		log.Printf("Replay: partition %d offset %d order %s rejected: %v", msg.Partition, msg.Offset, order.OrderUID, err)
+		report.Rejected++
+		return nil
+	case err != nil:
+		return err
+	}
+
+	switch res {
+	case repository.Inserted:
+		report.Inserted++
+	case repository.Updated:
+		report.Updated++
+	default:
+		report.Skipped++
+	}
+	return nil
+}
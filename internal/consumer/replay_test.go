@@ -0,0 +1,91 @@
+package consumer
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"testing"
+	"time"
+)
+
+// partitionStub отдаёт заранее заданные сообщения; верхняя граница партиции — hwm
+type partitionStub struct {
+	sarama.PartitionConsumer
+	messages chan *sarama.ConsumerMessage
+	hwm      int64
+}
+
+func (p *partitionStub) Messages() <-chan *sarama.ConsumerMessage { return p.messages }
+func (p *partitionStub) Errors() <-chan *sarama.ConsumerError     { return nil }
+func (p *partitionStub) HighWaterMarkOffset() int64               { return p.hwm }
+
+// offsetsStub запоминает последнее отмеченное смещение
+type offsetsStub struct {
+	sarama.PartitionOffsetManager
+	marked int64
+}
+
+func (o *offsetsStub) MarkOffset(offset int64, _ string) { o.marked = offset }
+
+// newPartitionStub возвращает партицию с сообщениями offsets; значения не разбираются, так что заказы отклоняются без бд
+func newPartitionStub(hwm int64, offsets ...int64) *partitionStub {
+	p := &partitionStub{messages: make(chan *sarama.ConsumerMessage, len(offsets)), hwm: hwm}
+	for _, off := range offsets {
+		p.messages <- &sarama.ConsumerMessage{Offset: off, Value: []byte("not an order")}
+	}
+	return p
+}
+
+func TestReplayConsume(t *testing.T) {
+	tests := []struct {
+		name       string
+		rng        OffsetRange
+		pc         *partitionStub
+		rejected   int
+		wantMarked int64
+	}{
+		{
+			name: "range read to the end",
+			rng:  OffsetRange{Start: 0, End: 3}, pc: newPartitionStub(3, 0, 1, 2),
+			rejected: 3, wantMarked: 3,
+		},
+		{
+			// Хвост диапазона — маркеры транзакций или удалён компактизацией: сообщений больше не будет
+			name: "tail never delivered",
+			rng:  OffsetRange{Start: 0, End: 5}, pc: newPartitionStub(5, 0, 1, 2),
+			rejected: 3, wantMarked: 3,
+		},
+		{
+			name: "next message beyond the range",
+			rng:  OffsetRange{Start: 0, End: 4}, pc: newPartitionStub(10, 0, 1, 6),
+			rejected: 2, wantMarked: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := partitionReplay{decoders: &decoders{format: "json"}, rng: tt.rng, idle: 10 * time.Millisecond}
+			pom := &offsetsStub{}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			report, err := r.consume(ctx, tt.pc, pom)
+			if err != nil {
+				t.Fatalf("consume: %v", err)
+			}
+			if report.Rejected != tt.rejected || pom.marked != tt.wantMarked {
+				t.Fatalf("consume = %+v, marked %d; want %d rejected, marked %d", report, pom.marked, tt.rejected, tt.wantMarked)
+			}
+		})
+	}
+}
+
+func TestReplayConsumeWaitsForPendingMessages(t *testing.T) {
+	// Граница партиции дальше конца диапазона: последнее сообщение ещё может прийти
+	pc := newPartitionStub(10, 0)
+	r := partitionReplay{decoders: &decoders{format: "json"}, rng: OffsetRange{Start: 0, End: 2}, idle: 10 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.consume(ctx, pc, &offsetsStub{}); err != context.DeadlineExceeded {
+		t.Fatalf("consume = %v, want it to wait until ctx is done", err)
+	}
+}
@@ -0,0 +1,138 @@
+package consumer
+
+import (
+	"WBTechL0/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+	"os"
+	"strings"
+)
+
+// newSaramaConfig собирает конфигурацию sarama из config.Kafka: версия протокола, client id, TLS и SASL
+func newSaramaConfig(cfgKafka config.Kafka) (*sarama.Config, error) {
+	cfg := sarama.NewConfig()
+	cfg.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	if cfgKafka.ClientId != "" {
+		cfg.ClientID = cfgKafka.ClientId
+	}
+
+	if cfgKafka.Version != "" {
+		version, err := sarama.ParseKafkaVersion(cfgKafka.Version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kafka version %q: %w", cfgKafka.Version, err)
+		}
+		cfg.Version = version
+	}
+
+	if cfgKafka.TLS.Enabled {
+		tlsCfg, err := newTLSConfig(cfgKafka.TLS)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tlsCfg
+	}
+
+	if cfgKafka.SASL.Enabled {
+		if err := applySASL(cfg, cfgKafka.SASL); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka config: %w", err)
+	}
+	return cfg, nil
+}
+
+// newTLSConfig загружает CA и клиентский сертификат для подключения к брокерам
+func newTLSConfig(cfgTLS config.KafkaTLS) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfgTLS.InsecureSkipVerify,
+	}
+
+	if cfgTLS.CAFile != "" {
+		caPEM, err := os.ReadFile(cfgTLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kafka CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in kafka CA file %s", cfgTLS.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	// Клиентский сертификат нужен только для mTLS, но задавать его надо целиком
+	if cfgTLS.CertFile != "" || cfgTLS.KeyFile != "" {
+		if cfgTLS.CertFile == "" || cfgTLS.KeyFile == "" {
+			return nil, errors.New("kafka tls: both certFile and keyFile must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(cfgTLS.CertFile, cfgTLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kafka client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// applySASL настраивает SASL/PLAIN или SASL/SCRAM
+func applySASL(cfg *sarama.Config, cfgSASL config.KafkaSASL) error {
+	if cfgSASL.User == "" {
+		return errors.New("kafka sasl: user must be set")
+	}
+
+	cfg.Net.SASL.Enable = true
+	cfg.Net.SASL.Handshake = true
+	cfg.Net.SASL.User = cfgSASL.User
+	cfg.Net.SASL.Password = cfgSASL.Password
+
+	switch strings.ToUpper(cfgSASL.Mechanism) {
+	case "", sarama.SASLTypePlaintext:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{HashGeneratorFcn: scram.SHA256}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{HashGeneratorFcn: scram.SHA512}
+		}
+	default:
+		return fmt.Errorf("kafka sasl: unsupported mechanism %q", cfgSASL.Mechanism)
+	}
+	return nil
+}
+
+// scramClient реализует sarama.SCRAMClient поверх xdg-go/scram
+type scramClient struct {
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.ClientConversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.ClientConversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.ClientConversation.Done()
+}
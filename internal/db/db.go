@@ -5,38 +5,139 @@ import (
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
 )
 
-// ConnectToDB подключается к базе данных, используя данные из конфига
-func ConnectToDB(dbCfg config.Database) (*pgxpool.Pool, error) {
-	connString := fmt.Sprintf("postgres://%s:%s@%s:%d/%s",
-		dbCfg.User,
-		dbCfg.Password,
-		dbCfg.Host,
-		dbCfg.Port,
-		dbCfg.DBname,
-	)
-	pool, err := pgxpool.New(context.Background(), connString)
+// minRetryBackoff — нижняя граница паузы между попытками: с нулевой паузой цикл крутился бы без остановки
+const minRetryBackoff = 100 * time.Millisecond
+
+// ConnectToDB подключается к базе данных, используя данные из конфига.
+// Пока база недоступна, повторяет попытки с экспоненциальной задержкой до отмены ctx
+func ConnectToDB(ctx context.Context, dbCfg config.Database, sl *slog.Logger) (*pgxpool.Pool, error) {
+	poolCfg, err := newPoolConfig(dbCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := max(dbCfg.RetryBackoff, minRetryBackoff)
+	for attempt := 1; ; attempt++ {
+		pool, err := connect(ctx, poolCfg)
+		if err == nil {
+			return pool, nil
+		}
+		if dbCfg.ConnectAttempts > 0 && attempt >= dbCfg.ConnectAttempts {
+			return nil, fmt.Errorf("failed to access the database after %d attempts: %w", attempt, err)
+		}
+
+		sl.Warn("Database is not available, retrying", "attempt", attempt, "retry_in", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to access the database after %d attempts: %w", attempt, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if dbCfg.RetryMaxBackoff > 0 && backoff > dbCfg.RetryMaxBackoff {
+			backoff = max(dbCfg.RetryMaxBackoff, minRetryBackoff)
+		}
+	}
+}
+
+// connect создаёт пул и проверяет, что база отвечает
+func connect(ctx context.Context, poolCfg *pgxpool.Config) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, err
 	}
 
 	// Check if the database is accessible by performing a simple query
-	err = pool.QueryRow(context.Background(), "SELECT 1").Scan(new(int))
+	err = pool.QueryRow(ctx, "SELECT 1").Scan(new(int))
 	if err != nil {
 		pool.Close()
-		return nil, fmt.Errorf("failed to access the database: %v", err)
+		return nil, err
 	}
 
 	return pool, nil
 }
 
-// CreateTables создает таблицы для хранения данных о заказах
-func CreateTables(pool *pgxpool.Pool) error {
-	createOrdersTable := `
+// newPoolConfig собирает конфигурацию пула: DSN, TLS, лимиты и таймауты
+func newPoolConfig(dbCfg config.Database) (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(buildDSN(dbCfg))
+	if err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+
+	if dbCfg.MaxConns > 0 {
+		poolCfg.MaxConns = dbCfg.MaxConns
+	}
+	if dbCfg.MinConns > 0 {
+		poolCfg.MinConns = dbCfg.MinConns
+	}
+	if dbCfg.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = dbCfg.MaxConnLifetime
+	}
+	if dbCfg.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = dbCfg.MaxConnIdleTime
+	}
+	if dbCfg.HealthCheckPeriod > 0 {
+		poolCfg.HealthCheckPeriod = dbCfg.HealthCheckPeriod
+	}
+	if dbCfg.ConnectTimeout > 0 {
+		poolCfg.ConnConfig.ConnectTimeout = dbCfg.ConnectTimeout
+	}
+
+	// Параметры сессии, если они не заданы в самом DSN
+	params := poolCfg.ConnConfig.RuntimeParams
+	if _, ok := params["application_name"]; !ok && dbCfg.ApplicationName != "" {
+		params["application_name"] = dbCfg.ApplicationName
+	}
+	if _, ok := params["statement_timeout"]; !ok && dbCfg.StatementTimeout > 0 {
+		params["statement_timeout"] = strconv.FormatInt(dbCfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	return poolCfg, nil
+}
+
+// buildDSN возвращает DSN из конфига; логин и пароль экранируются
+func buildDSN(dbCfg config.Database) string {
+	if dbCfg.DSN != "" {
+		return dbCfg.DSN
+	}
+
+	query := url.Values{}
+	if dbCfg.SSLMode != "" {
+		query.Set("sslmode", dbCfg.SSLMode)
+	}
+	if dbCfg.SSLRootCert != "" {
+		query.Set("sslrootcert", dbCfg.SSLRootCert)
+	}
+	if dbCfg.SSLCert != "" {
+		query.Set("sslcert", dbCfg.SSLCert)
+	}
+	if dbCfg.SSLKey != "" {
+		query.Set("sslkey", dbCfg.SSLKey)
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(dbCfg.User, dbCfg.Password),
+		Host:     net.JoinHostPort(dbCfg.Host, strconv.Itoa(dbCfg.Port)),
+		Path:     "/" + dbCfg.DBname,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// createOrdersTable — таблица заказов, секционированная по месяцам date_created.
+// Уникальные ключи секционированной таблицы обязаны включать ключ секционирования,
+// поэтому первичный ключ — (order_uid, date_created), а track_number больше не уникален
+const createOrdersTable = `
 	CREATE TABLE IF NOT EXISTS orders (
-		order_uid VARCHAR(255) PRIMARY KEY,
-		track_number VARCHAR(255) NOT NULL UNIQUE,
+		order_uid VARCHAR(255) NOT NULL,
+		track_number VARCHAR(255) NOT NULL,
 		entry VARCHAR(255) NOT NULL,
 		locale VARCHAR(10) NOT NULL,
 		internal_signature VARCHAR(255),
@@ -47,21 +148,47 @@ func CreateTables(pool *pgxpool.Pool) error {
 		date_created TIMESTAMP NOT NULL,
 		oof_shard VARCHAR(255) NOT NULL,
 		delivery_id INT REFERENCES deliveries(id),  -- Внешний ключ на таблицу deliveries
-		payment_id INT REFERENCES payments(id)       -- Внешний ключ на таблицу payments
-	);`
+		payment_id INT REFERENCES payments(id),     -- Внешний ключ на таблицу payments
+		PRIMARY KEY (order_uid, date_created)
+	) PARTITION BY RANGE (date_created);
+	CREATE TABLE IF NOT EXISTS orders_default PARTITION OF orders DEFAULT;`
 
+// CreateTables создает таблицы для хранения данных о заказах
+func CreateTables(pool *pgxpool.Pool, sl *slog.Logger) error {
+	// name, phone, zip, address и email хранятся зашифрованными, если задан файл ключей:
+	// key_id — KEK, которым обёрнут ключ записи dek; *_bidx — слепые индексы для поиска
 	createDeliveriesTable := `
 	CREATE TABLE IF NOT EXISTS deliveries (
 		id SERIAL PRIMARY KEY,
-		name VARCHAR(255) NOT NULL,
-		phone VARCHAR(20) NOT NULL,
-		zip VARCHAR(7) NOT NULL,
+		name TEXT NOT NULL,
+		phone TEXT NOT NULL,
+		zip TEXT NOT NULL,
 		city VARCHAR(255) NOT NULL,
-		address VARCHAR(255) NOT NULL,
+		address TEXT NOT NULL,
 		region VARCHAR(255) NOT NULL,
-		email VARCHAR(255) NOT NULL
+		email TEXT NOT NULL,
+		key_id VARCHAR(64),
+		dek BYTEA,
+		email_bidx BYTEA,
+		phone_bidx BYTEA
 	);`
 
+	// Миграция таблиц, созданных до появления шифрования
+	migrateDeliveriesTable := `
+	ALTER TABLE deliveries
+		ALTER COLUMN name TYPE TEXT,
+		ALTER COLUMN phone TYPE TEXT,
+		ALTER COLUMN zip TYPE TEXT,
+		ALTER COLUMN address TYPE TEXT,
+		ALTER COLUMN email TYPE TEXT,
+		ADD COLUMN IF NOT EXISTS key_id VARCHAR(64),
+		ADD COLUMN IF NOT EXISTS dek BYTEA,
+		ADD COLUMN IF NOT EXISTS email_bidx BYTEA,
+		ADD COLUMN IF NOT EXISTS phone_bidx BYTEA;
+	CREATE INDEX IF NOT EXISTS deliveries_email_bidx_idx ON deliveries (email_bidx);
+	CREATE INDEX IF NOT EXISTS deliveries_phone_bidx_idx ON deliveries (phone_bidx);
+	CREATE INDEX IF NOT EXISTS deliveries_key_id_idx ON deliveries (key_id);`
+
 	createPaymentsTable := `
 	CREATE TABLE IF NOT EXISTS payments (
 		id SERIAL PRIMARY KEY,
@@ -77,11 +204,14 @@ func CreateTables(pool *pgxpool.Pool) error {
 		custom_fee INT CHECK (custom_fee >= 0)
 	);`
 
+	// Товары ссылаются на первичный ключ заказа и удаляются вместе с ним
 	createItemsTable := `
 	CREATE TABLE IF NOT EXISTS items (
 		id SERIAL PRIMARY KEY,
+		order_uid VARCHAR(255) NOT NULL,
+		order_date_created TIMESTAMP NOT NULL,
 		chrt_id INT NOT NULL,
-		track_number VARCHAR(255) REFERENCES orders(track_number), -- Внешний ключ на таблицу orders
+		track_number VARCHAR(255),
 		price INT NOT NULL CHECK (price >= 0),
 		rid VARCHAR(255) NOT NULL,
 		name VARCHAR(255) NOT NULL,
@@ -90,15 +220,283 @@ func CreateTables(pool *pgxpool.Pool) error {
 		total_price INT NOT NULL CHECK (total_price >= 0),
 		nm_id INT NOT NULL,
 		brand VARCHAR(255) NOT NULL,
+		status INT NOT NULL,
+		FOREIGN KEY (order_uid, order_date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE
+	);`
+
+	// Архив: сюда задача хранения переносит старые заказы и их товары.
+	// Доставки и платежи остаются на месте, поэтому шифрование и удаление данных работают и для архива
+	createArchiveTables := `
+	CREATE TABLE IF NOT EXISTS orders_archive (
+		order_uid VARCHAR(255) PRIMARY KEY,
+		track_number VARCHAR(255) NOT NULL,
+		entry VARCHAR(255) NOT NULL,
+		locale VARCHAR(10) NOT NULL,
+		internal_signature VARCHAR(255),
+		customer_id VARCHAR(255) NOT NULL,
+		delivery_service VARCHAR(255) NOT NULL,
+		shardkey VARCHAR(255) NOT NULL,
+		sm_id INT NOT NULL,
+		date_created TIMESTAMP NOT NULL,
+		oof_shard VARCHAR(255) NOT NULL,
+		delivery_id INT REFERENCES deliveries(id),
+		payment_id INT REFERENCES payments(id),
+		archived_at TIMESTAMP NOT NULL DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS orders_archive_customer_id_idx ON orders_archive (customer_id);
+
+	CREATE TABLE IF NOT EXISTS items_archive (
+		id INT PRIMARY KEY,
+		order_uid VARCHAR(255) NOT NULL REFERENCES orders_archive (order_uid) ON DELETE CASCADE,
+		chrt_id INT NOT NULL,
+		track_number VARCHAR(255),
+		price INT NOT NULL,
+		rid VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		sale INT,
+		size VARCHAR(50) NOT NULL,
+		total_price INT NOT NULL,
+		nm_id INT NOT NULL,
+		brand VARCHAR(255) NOT NULL,
 		status INT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS items_archive_order_uid_idx ON items_archive (order_uid);`
+
+	// Курсы валют к валюте отчётности: rate единиц базовой валюты за одну единицу currency с даты valid_from
+	createExchangeRatesTable := `
+	CREATE TABLE IF NOT EXISTS exchange_rates (
+		currency CHAR(3) NOT NULL,
+		valid_from DATE NOT NULL,
+		rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+		PRIMARY KEY (currency, valid_from)
 	);`
 
+	// Расхождения в суммах заказов, найденные при приёме (режим reconciliation flag)
+	createReconciliationTable := `
+	CREATE TABLE IF NOT EXISTS reconciliation_issues (
+		id SERIAL PRIMARY KEY,
+		order_uid VARCHAR(255) NOT NULL,
+		check_name VARCHAR(50) NOT NULL,
+		item INT NOT NULL DEFAULT 0,
+		expected INT NOT NULL,
+		actual INT NOT NULL,
+		detected_at TIMESTAMP NOT NULL DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS reconciliation_issues_order_uid_idx ON reconciliation_issues (order_uid);`
+
+	// Поисковые документы заказов (основных и архивных); заполняются репозиторием при сохранении
+	createSearchTable := `
+	CREATE TABLE IF NOT EXISTS order_search (
+		order_uid VARCHAR(255) PRIMARY KEY,
+		date_created TIMESTAMP NOT NULL,
+		document TSVECTOR NOT NULL,
+		pii_document TSVECTOR,
+		name_tokens BYTEA[]
+	);
+	CREATE INDEX IF NOT EXISTS order_search_document_idx ON order_search USING GIN (document);
+	CREATE INDEX IF NOT EXISTS order_search_pii_document_idx ON order_search USING GIN (pii_document);
+	CREATE INDEX IF NOT EXISTS order_search_name_tokens_idx ON order_search USING GIN (name_tokens);`
+
+	// Transactional outbox: события о заказах пишутся в транзакции сохранения и публикуются в Kafka отдельно
+	createOutboxTable := `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		order_uid VARCHAR(255) NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		published_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+	CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
+	-- Ранние версии писали новые заказы как order.stored, который консюмеры не принимают
+	UPDATE outbox SET event_type = 'order.created' WHERE event_type = 'order.stored' AND published_at IS NULL;`
+
+	// Журнал изменений заказов: по нему снимок кэша догоняет базу
+	createOrderChangesTable := `
+	CREATE TABLE IF NOT EXISTS order_changes (
+		order_uid VARCHAR(255) PRIMARY KEY,
+		changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS order_changes_changed_at_idx ON order_changes (changed_at);`
+
+	// Представления для чтения без разницы, лежит заказ в основных таблицах или в архиве
+	createViews := `
+	CREATE INDEX IF NOT EXISTS orders_customer_id_idx ON orders (customer_id);
+	CREATE INDEX IF NOT EXISTS orders_date_created_idx ON orders (date_created);
+
+	CREATE OR REPLACE VIEW all_orders AS
+	SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+	       shardkey, sm_id, date_created, oof_shard, delivery_id, payment_id
+	FROM orders
+	UNION ALL
+	SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+	       shardkey, sm_id, date_created, oof_shard, delivery_id, payment_id
+	FROM orders_archive;
+
+	-- order_date_created вместе с order_uid — ключ заказа: UID в orders и orders_archive может повторяться.
+	-- У архивных товаров даты нет, её даёт архивный заказ (order_uid в архиве уникален)
+	CREATE OR REPLACE VIEW all_items AS
+	SELECT id, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid, order_date_created FROM items
+	UNION ALL
+	SELECT i.id, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status, i.order_uid, a.date_created
+	FROM items_archive i
+	JOIN orders_archive a ON a.order_uid = i.order_uid;`
+
+	// Материализованные представления отчётов; пересчитываются по расписанию (RefreshAnalytics).
+	// Уникальные индексы нужны для REFRESH ... CONCURRENTLY
+	createAnalyticsViews := `
+	CREATE MATERIALIZED VIEW IF NOT EXISTS order_stats_daily AS
+	SELECT o.date_created::date AS day, o.delivery_service, d.region, p.provider, p.bank, p.currency,
+	       count(*)::bigint AS orders, sum(p.amount)::bigint AS revenue
+	FROM all_orders o
+	JOIN deliveries d ON d.id = o.delivery_id
+	JOIN payments p ON p.id = o.payment_id
+	GROUP BY 1, 2, 3, 4, 5, 6;
+	CREATE UNIQUE INDEX IF NOT EXISTS order_stats_daily_key_idx
+		ON order_stats_daily (day, delivery_service, region, provider, bank, currency);
+
+	CREATE MATERIALIZED VIEW IF NOT EXISTS brand_stats_daily AS
+	SELECT o.date_created::date AS day, i.brand, p.currency,
+	       count(DISTINCT o.order_uid)::bigint AS orders, sum(i.total_price)::bigint AS revenue
+	FROM all_items i
+	JOIN all_orders o ON o.order_uid = i.order_uid
+	JOIN payments p ON p.id = o.payment_id
+	GROUP BY 1, 2, 3;
+	CREATE UNIQUE INDEX IF NOT EXISTS brand_stats_daily_key_idx ON brand_stats_daily (day, brand, currency);`
+
 	// Выполняем команды для создания таблиц
-	commands := []string{createDeliveriesTable, createPaymentsTable, createOrdersTable, createItemsTable}
-	for _, cmd := range commands {
-		if _, err := pool.Exec(context.Background(), cmd); err != nil {
+	ctx := context.Background()
+	for _, cmd := range []string{createDeliveriesTable, migrateDeliveriesTable, createPaymentsTable} {
+		if _, err := pool.Exec(ctx, cmd); err != nil {
+			return err
+		}
+	}
+
+	// Таблицу orders, созданную до секционирования, переносим на секции
+	if err := migrateOrdersToPartitioned(ctx, pool); err != nil {
+		return fmt.Errorf("failed to migrate orders to partitioned table: %w", err)
+	}
+
+	for _, cmd := range []string{createOrdersTable, createItemsTable, createArchiveTables} {
+		if _, err := pool.Exec(ctx, cmd); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateItemsToOrderKeys(ctx, pool, sl); err != nil {
+		return fmt.Errorf("failed to link items to orders: %w", err)
+	}
+
+	for _, cmd := range []string{createExchangeRatesTable, createReconciliationTable, createSearchTable, createOutboxTable, createOrderChangesTable, createViews, createAnalyticsViews} {
+		if _, err := pool.Exec(ctx, cmd); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	// Секция на текущий месяц нужна сразу, будущие создаёт Partitioner
+	return EnsurePartitions(ctx, pool, time.Now(), 0)
+}
+
+// migrateItemsToOrderKeys переводит товары, связанные с заказами по track_number, на ключ заказа
+// и включает внешние ключи. Если track_number повторяется, товар достаётся самому раннему заказу
+// (при равных датах — с меньшим order_uid). Товары без заказа не удаляются, а переносятся
+// в items_quarantine для разбора вручную. Повторный запуск ничего не меняет
+func migrateItemsToOrderKeys(ctx context.Context, pool *pgxpool.Pool, sl *slog.Logger) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	prepare := `
+	ALTER TABLE items
+		ADD COLUMN IF NOT EXISTS order_uid VARCHAR(255),
+		ADD COLUMN IF NOT EXISTS order_date_created TIMESTAMP;
+	ALTER TABLE items_archive
+		ADD COLUMN IF NOT EXISTS order_uid VARCHAR(255);
+
+	CREATE TABLE IF NOT EXISTS items_quarantine (
+		source VARCHAR(32) NOT NULL, -- items или items_archive
+		id INT NOT NULL,
+		chrt_id INT NOT NULL,
+		track_number VARCHAR(255),
+		price INT NOT NULL,
+		rid VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		sale INT,
+		size VARCHAR(50) NOT NULL,
+		total_price INT NOT NULL,
+		nm_id INT NOT NULL,
+		brand VARCHAR(255) NOT NULL,
+		status INT NOT NULL,
+		quarantined_at TIMESTAMP NOT NULL DEFAULT now(),
+		PRIMARY KEY (source, id)
+	);
+
+	UPDATE items i
+	SET order_uid = o.order_uid, order_date_created = o.date_created
+	FROM (
+		SELECT DISTINCT ON (track_number) track_number, order_uid, date_created
+		FROM orders
+		ORDER BY track_number, date_created, order_uid
+	) o
+	WHERE i.order_uid IS NULL AND i.track_number = o.track_number;
+
+	UPDATE items_archive i
+	SET order_uid = o.order_uid
+	FROM (
+		SELECT DISTINCT ON (track_number) track_number, order_uid
+		FROM orders_archive
+		ORDER BY track_number, date_created, order_uid
+	) o
+	WHERE i.order_uid IS NULL AND i.track_number = o.track_number;`
+	if _, err = tx.Exec(ctx, prepare); err != nil {
+		return err
+	}
+
+	quarantine := `
+	WITH moved AS (
+		DELETE FROM %[1]s WHERE order_uid IS NULL
+		RETURNING id, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+	)
+	INSERT INTO items_quarantine (source, id, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
+	SELECT '%[1]s', id, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM moved`
+	for _, table := range []string{"items", "items_archive"} {
+		tag, err := tx.Exec(ctx, fmt.Sprintf(quarantine, table))
+		if err != nil {
+			return err
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			sl.Warn("Items without a matching order moved to items_quarantine", "table", table, "items", n)
+		}
+	}
+
+	finish := `
+	ALTER TABLE items
+		ALTER COLUMN order_uid SET NOT NULL,
+		ALTER COLUMN order_date_created SET NOT NULL;
+	ALTER TABLE items_archive
+		ALTER COLUMN order_uid SET NOT NULL;
+
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'items_order_uid_order_date_created_fkey') THEN
+			ALTER TABLE items ADD CONSTRAINT items_order_uid_order_date_created_fkey
+				FOREIGN KEY (order_uid, order_date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE;
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'items_archive_order_uid_fkey') THEN
+			ALTER TABLE items_archive ADD CONSTRAINT items_archive_order_uid_fkey
+				FOREIGN KEY (order_uid) REFERENCES orders_archive (order_uid) ON DELETE CASCADE;
+		END IF;
+	END $$;
+
+	DROP INDEX IF EXISTS items_track_number_idx;
+	DROP INDEX IF EXISTS items_archive_track_number_idx;
+	CREATE INDEX IF NOT EXISTS items_order_uid_idx ON items (order_uid, order_date_created);
+	CREATE INDEX IF NOT EXISTS items_archive_order_uid_idx ON items_archive (order_uid);`
+	if _, err = tx.Exec(ctx, finish); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// OrderChangesChannel — канал LISTEN/NOTIFY, в который пишутся UID изменённых заказов.
+// Уведомление отправляется в транзакции изменения и доставляется слушателям только после коммита
+const OrderChangesChannel = "order_changes"
+
+// InstanceID отличает экземпляры приложения: слушатель пропускает изменения, сделанные своим экземпляром,
+// — их он уже отразил в кэше сам
+var InstanceID = newInstanceID()
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// OrderChange — уведомление об изменении заказа. Пустой OrderUID означает, что изменилось много заказов сразу
+type OrderChange struct {
+	Origin   string `json:"origin"`
+	OrderUID string `json:"order_uid,omitempty"`
+}
+
+// execer — общее для пула и транзакции
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// RecordOrderChanges отмечает заказы из uids в журнале order_changes и отправляет уведомление по каждому;
+// вызывается в транзакции изменения
+func RecordOrderChanges(ctx context.Context, q execer, uids ...string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	recordQuery := `
+	WITH changed AS (
+		INSERT INTO order_changes (order_uid, changed_at)
+		SELECT DISTINCT uid, now() FROM unnest($3::text[]) AS uid
+		ON CONFLICT (order_uid) DO UPDATE SET changed_at = EXCLUDED.changed_at
+		RETURNING order_uid
+	)
+	SELECT pg_notify($1, json_build_object('origin', $2::text, 'order_uid', order_uid)::text)
+	FROM changed`
+	_, err := q.Exec(ctx, recordQuery, OrderChangesChannel, InstanceID, uids)
+	return err
+}
+
+// notifyAllOrdersChanged сообщает, что изменилось много заказов и кэш нужно сбросить целиком
+func notifyAllOrdersChanged(ctx context.Context, q execer) error {
+	payload, err := json.Marshal(OrderChange{Origin: InstanceID})
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `SELECT pg_notify($1, $2)`, OrderChangesChannel, string(payload))
+	return err
+}
+
+// OrderChangeListener получает уведомления об изменениях заказов, сделанных другими экземплярами
+type OrderChangeListener struct {
+	pool *pgxpool.Pool
+	sl   *slog.Logger
+	// changed вызывается для каждого изменённого заказа
+	changed func(orderUID string)
+	// reset вызывается, когда изменилось много заказов или уведомления могли потеряться
+	reset func()
+	// subscribed закрывается после первой подписки на канал
+	subscribed chan struct{}
+}
+
+// NewOrderChangeListener создаёт слушателя уведомлений об изменениях заказов
+func NewOrderChangeListener(pool *pgxpool.Pool, sl *slog.Logger, changed func(orderUID string), reset func()) *OrderChangeListener {
+	return &OrderChangeListener{pool: pool, sl: sl, changed: changed, reset: reset, subscribed: make(chan struct{})}
+}
+
+// Subscribed закрывается, когда слушатель впервые подписался на канал: изменения после этого не теряются
+func (l *OrderChangeListener) Subscribed() <-chan struct{} {
+	return l.subscribed
+}
+
+// Run слушает канал до отмены ctx, переподключаясь при обрыве соединения.
+// Пока соединения нет, уведомления теряются, поэтому после переподключения вызывается reset
+func (l *OrderChangeListener) Run(ctx context.Context) {
+	const maxBackoff = 30 * time.Second
+	backoff := time.Second
+
+	for connected := false; ; {
+		err := l.listen(ctx, func() {
+			if connected {
+				l.reset()
+			} else {
+				close(l.subscribed)
+			}
+			connected = true
+			backoff = time.Second
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		l.sl.Error("Order changes listener disconnected", "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// listen держит отдельное соединение из пула с подпиской на канал; subscribed вызывается после LISTEN
+func (l *OrderChangeListener) listen(ctx context.Context, subscribed func()) error {
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// Соединение с подпиской не возвращается в пул: закрываем его вместе со слушателем
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+OrderChangesChannel); err != nil {
+		return err
+	}
+	subscribed()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var change OrderChange
+		if err = json.Unmarshal([]byte(n.Payload), &change); err != nil {
+			l.sl.Error("Invalid order change notification", "payload", n.Payload, "error", err)
+			continue
+		}
+		switch {
+		case change.OrderUID == "":
+			l.reset()
+		case change.Origin != InstanceID:
+			l.changed(change.OrderUID)
+		}
+	}
+}
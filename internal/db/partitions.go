@@ -0,0 +1,267 @@
+package db
+
+import (
+	"WBTechL0/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// Секции таблицы orders помесячные: orders_pYYYYMM хранит заказы с date_created
+// в [1-е число месяца, 1-е число следующего месяца). Всё, что не попало ни в одну секцию,
+// попадает в orders_default и переносится в свою секцию при её создании.
+
+const partitionLayout = "orders_p200601"
+
+// partitionName возвращает имя секции для месяца, которому принадлежит t
+func partitionName(t time.Time) string {
+	return monthStart(t).Format(partitionLayout)
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// EnsurePartitions создаёт секции с месяца from по from+ahead месяцев включительно, если их ещё нет
+func EnsurePartitions(ctx context.Context, pool *pgxpool.Pool, from time.Time, ahead int) error {
+	existing, err := listPartitions(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	month := monthStart(from)
+	for i := 0; i <= ahead; i++ {
+		if _, ok := existing[partitionName(month)]; !ok {
+			if err = createPartition(ctx, pool, month); err != nil {
+				return err
+			}
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+	return nil
+}
+
+// createPartition создаёт секцию на месяц. Строки этого месяца, уже попавшие в orders_default,
+// переносятся в новую секцию: иначе Postgres не даст её создать
+func createPartition(ctx context.Context, pool *pgxpool.Pool, month time.Time) error {
+	name := partitionName(month)
+	from, to := month.Format(time.DateOnly), month.AddDate(0, 1, 0).Format(time.DateOnly)
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Секцию orders_default нельзя отсоединить, пока на её строки ссылаются товары,
+	// поэтому строки месяца вместе с товарами переносятся через временные таблицы
+	inRange := fmt.Sprintf(`date_created >= '%s' AND date_created < '%s'`, from, to)
+	stmts := []string{
+		`CREATE TEMP TABLE moved_orders ON COMMIT DROP AS SELECT * FROM orders_default WHERE ` + inRange,
+		`CREATE TEMP TABLE moved_items ON COMMIT DROP AS
+		SELECT i.* FROM items i JOIN moved_orders o ON i.order_uid = o.order_uid AND i.order_date_created = o.date_created`,
+		`DELETE FROM orders_default WHERE ` + inRange,
+		fmt.Sprintf(`CREATE TABLE %s PARTITION OF orders FOR VALUES FROM ('%s') TO ('%s')`, name, from, to),
+		`INSERT INTO orders SELECT * FROM moved_orders`,
+		`INSERT INTO items SELECT * FROM moved_items`,
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", name, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// listPartitions возвращает помесячные секции orders с началом их диапазона
+func listPartitions(ctx context.Context, pool *pgxpool.Pool) (map[string]time.Time, error) {
+	rows, err := pool.Query(ctx, `
+	SELECT c.relname
+	FROM pg_inherits i
+	JOIN pg_class c ON c.oid = i.inhrelid
+	WHERE i.inhparent = 'orders'::regclass`)
+	if err != nil {
+		return nil, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make(map[string]time.Time, len(names))
+	for _, name := range names {
+		// orders_default и секции, созданные не нами, пропускаем
+		if month, err := time.Parse(partitionLayout, name); err == nil {
+			partitions[name] = month
+		}
+	}
+	return partitions, nil
+}
+
+// DropPartitionsBefore отсоединяет и удаляет секции, целиком лежащие раньше before,
+// вместе с товарами, доставками и платежами их заказов. Возвращает имена удалённых секций
+func DropPartitionsBefore(ctx context.Context, pool *pgxpool.Pool, before time.Time) ([]string, error) {
+	partitions, err := listPartitions(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for name, month := range partitions {
+		if month.AddDate(0, 1, 0).After(before) {
+			continue
+		}
+		if err = dropPartition(ctx, pool, name); err != nil {
+			return dropped, err
+		}
+		dropped = append(dropped, name)
+	}
+	return dropped, nil
+}
+
+func dropPartition(ctx context.Context, pool *pgxpool.Pool, name string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	stmts := []string{
+		fmt.Sprintf(`CREATE TEMP TABLE dropped_orders ON COMMIT DROP AS SELECT order_uid, delivery_id, payment_id FROM %s`, name),
+		// Товары удаляются каскадно; без этого секцию, на которую ссылаются товары, нельзя отсоединить
+		fmt.Sprintf(`DELETE FROM %s`, name),
+		fmt.Sprintf(`ALTER TABLE orders DETACH PARTITION %s`, name),
+		fmt.Sprintf(`DROP TABLE %s`, name),
+		`DELETE FROM order_search WHERE order_uid IN (SELECT order_uid FROM dropped_orders)`,
+		`DELETE FROM deliveries WHERE id IN (SELECT delivery_id FROM dropped_orders)`,
+		`DELETE FROM payments WHERE id IN (SELECT payment_id FROM dropped_orders)`,
+		`INSERT INTO order_changes (order_uid) SELECT order_uid FROM dropped_orders
+		 ON CONFLICT (order_uid) DO UPDATE SET changed_at = now()`,
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+	}
+	// Заказов в секции может быть много: вместо уведомления по каждому сбрасываем кэши целиком
+	if err = notifyAllOrdersChanged(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// migrateOrdersToPartitioned переносит данные из обычной таблицы orders, созданной
+// до появления секционирования, в секционированную. Для новой базы ничего не делает
+func migrateOrdersToPartitioned(ctx context.Context, pool *pgxpool.Pool) error {
+	var kind string
+	err := pool.QueryRow(ctx, `SELECT relkind::text FROM pg_class WHERE oid = to_regclass('orders')`).Scan(&kind)
+	if errors.Is(err, pgx.ErrNoRows) || kind == "p" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Освобождаем имена ограничений и индексов: новая таблица создаётся с теми же
+	prepare := []string{
+		`DROP VIEW IF EXISTS all_orders`,
+		`ALTER TABLE items DROP CONSTRAINT IF EXISTS items_track_number_fkey`,
+		`ALTER TABLE orders RENAME TO orders_unpartitioned`,
+		`ALTER TABLE orders_unpartitioned DROP CONSTRAINT IF EXISTS orders_pkey`,
+		`ALTER TABLE orders_unpartitioned DROP CONSTRAINT IF EXISTS orders_track_number_key`,
+		`DROP INDEX IF EXISTS orders_customer_id_idx`,
+		`DROP INDEX IF EXISTS orders_date_created_idx`,
+		createOrdersTable,
+	}
+	for _, stmt := range prepare {
+		if _, err = tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	// Секции на весь диапазон существующих данных
+	var oldest *time.Time
+	if err = tx.QueryRow(ctx, `SELECT min(date_created) FROM orders_unpartitioned`).Scan(&oldest); err != nil {
+		return err
+	}
+	if oldest != nil {
+		for month := monthStart(*oldest); !month.After(time.Now()); month = month.AddDate(0, 1, 0) {
+			stmt := fmt.Sprintf(`CREATE TABLE %s PARTITION OF orders FOR VALUES FROM ('%s') TO ('%s')`,
+				partitionName(month), month.Format(time.DateOnly), month.AddDate(0, 1, 0).Format(time.DateOnly))
+			if _, err = tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	}
+
+	finish := []string{
+		`INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+		                     shardkey, sm_id, date_created, oof_shard, delivery_id, payment_id)
+		SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+		       shardkey, sm_id, date_created, oof_shard, delivery_id, payment_id
+		FROM orders_unpartitioned`,
+		`DROP TABLE orders_unpartitioned`,
+	}
+	for _, stmt := range finish {
+		if _, err = tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// Partitioner — периодическое обслуживание секций: создание будущих и удаление старых
+type Partitioner struct {
+	pool *pgxpool.Pool
+	cfg  config.Partitioning
+	sl   *slog.Logger
+}
+
+// NewPartitioner создаёт Partitioner
+func NewPartitioner(pool *pgxpool.Pool, cfg config.Partitioning, sl *slog.Logger) *Partitioner {
+	return &Partitioner{pool: pool, cfg: cfg, sl: sl}
+}
+
+// Run обслуживает секции сразу и затем с интервалом из конфига, пока не завершится ctx
+func (p *Partitioner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Maintain(ctx); err != nil {
+			p.sl.Error("Partition maintenance failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Maintain создаёт секции на PremakeMonths вперёд и удаляет секции старше DropAfter
+func (p *Partitioner) Maintain(ctx context.Context) error {
+	if err := EnsurePartitions(ctx, p.pool, time.Now(), p.cfg.PremakeMonths); err != nil {
+		return err
+	}
+
+	if p.cfg.DropAfter <= 0 {
+		return nil
+	}
+	dropped, err := DropPartitionsBefore(ctx, p.pool, time.Now().Add(-p.cfg.DropAfter))
+	if len(dropped) > 0 {
+		p.sl.Info("Old order partitions dropped", "partitions", dropped)
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"WBTechL0/internal/money"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnknownDimension — отчёт по неизвестному разрезу
+var ErrUnknownDimension = errors.New("unknown analytics dimension")
+
+// Разрезы отчётов: из какого материализованного представления и по какому выражению группировать
+var dimensions = map[string]struct {
+	view string
+	key  string
+}{
+	"day":              {view: "order_stats_daily", key: "to_char(m.day, 'YYYY-MM-DD')"},
+	"delivery_service": {view: "order_stats_daily", key: "m.delivery_service"},
+	"region":           {view: "order_stats_daily", key: "m.region"},
+	"provider":         {view: "order_stats_daily", key: "m.provider"},
+	"bank":             {view: "order_stats_daily", key: "m.bank"},
+	"brand":            {view: "brand_stats_daily", key: "m.brand"},
+}
+
+// IsDimension сообщает, поддерживается ли разрез
+func IsDimension(name string) bool {
+	_, ok := dimensions[name]
+	return ok
+}
+
+// StatRow — строка отчёта: количество заказов и выручка в валюте отчётности.
+// Unconverted — заказы в валютах без курса на дату заказа; в выручку они не входят.
+// В разрезе brand выручка — сумма товаров бренда, а не заказов целиком
+type StatRow struct {
+	Key         string
+	Orders      int64
+	Revenue     money.Money
+	Unconverted int64
+}
+
+// RefreshAnalytics пересчитывает материализованные представления отчётов, не блокируя чтение
+func (r *Repo) RefreshAnalytics(ctx context.Context) error {
+	for _, view := range []string{"order_stats_daily", "brand_stats_daily"} {
+		if _, err := r.pool.Exec(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY "+view); err != nil {
+			r.sl.Error("Failed to refresh analytics view", "view", view, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStats строит отчёт по разрезу dimension за дни с from по to включительно.
+// Суммы пересчитываются в base по последнему курсу на день заказа
+func (r *Repo) GetStats(ctx context.Context, dimension string, from, to time.Time, base money.Currency) ([]StatRow, error) {
+	dim, ok := dimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDimension, dimension)
+	}
+
+	order := "orders DESC, key"
+	if dimension == "day" {
+		order = "key"
+	}
+
+	query := fmt.Sprintf(`
+	SELECT key,
+	       sum(orders)::bigint AS orders,
+	       coalesce(round(sum(revenue * rate) * 10::numeric ^ $4::int), 0)::bigint AS revenue,
+	       coalesce(sum(orders) FILTER (WHERE rate IS NULL), 0)::bigint AS unconverted
+	FROM (
+		SELECT %s AS key, m.orders, m.revenue,
+		       CASE WHEN m.currency = $3 THEN 1 ELSE er.rate END AS rate
+		FROM %s m
+		LEFT JOIN LATERAL (
+			SELECT rate FROM exchange_rates
+			WHERE currency = m.currency AND valid_from <= m.day
+			ORDER BY valid_from DESC
+			LIMIT 1
+		) er ON true
+		WHERE m.day BETWEEN $1 AND $2
+	) s
+	GROUP BY key
+	ORDER BY %s`, dim.key, dim.view, order)
+
+	rows, err := r.pool.Query(ctx, query, from, to, base.Code, base.Exponent)
+	if err != nil {
+		r.sl.Error("Failed to build analytics report", "dimension", dimension, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []StatRow
+	for rows.Next() {
+		row := StatRow{Revenue: money.Money{Currency: base}}
+		if err = rows.Scan(&row.Key, &row.Orders, &row.Revenue.Amount, &row.Unconverted); err != nil {
+			r.sl.Error("Failed to scan analytics row", "error", err)
+			return nil, err
+		}
+		stats = append(stats, row)
+	}
+	if err = rows.Err(); err != nil {
+		r.sl.Error("Error during rows iteration", "error", err)
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"WBTechL0/internal/db"
+	"context"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// ArchiveOrdersBefore переносит в архив до batchSize заказов, созданных раньше before,
+// вместе с их товарами. Возвращает UID перенесённых заказов и сколько заказов было выбрано:
+// выбрано меньше batchSize — переносить больше нечего
+func (r *Repo) ArchiveOrdersBefore(ctx context.Context, before time.Time, batchSize int) ([]string, int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		r.sl.Error("Failed to start transaction", "error", err)
+		return nil, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Заказы, UID которых уже есть в архиве, перенести нельзя: они остаются в основных таблицах
+	// и не должны занимать место в пачке, иначе перенос остановится на них навсегда
+	selectQuery := `
+	SELECT o.order_uid, o.date_created
+	FROM orders o
+	WHERE o.date_created < $1
+	  AND NOT EXISTS (SELECT 1 FROM orders_archive a WHERE a.order_uid = o.order_uid)
+	ORDER BY o.date_created
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, selectQuery, before, batchSize)
+	if err != nil {
+		r.sl.Error("Failed to select orders for archiving", "error", err)
+		return nil, 0, err
+	}
+	selected, err := pgx.CollectRows(rows, pgx.RowToStructByPos[orderKey])
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(selected) == 0 {
+		return nil, 0, nil
+	}
+	uids, dates := splitKeys(selected)
+
+	// Сначала копируем заказы: товары в архиве ссылаются на архивные заказы.
+	// Заказ, UID которого уже есть в архиве, не переносится и остаётся в основных таблицах
+	copyOrdersQuery := `
+	INSERT INTO orders_archive (order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+	                            shardkey, sm_id, date_created, oof_shard, delivery_id, payment_id)
+	SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service,
+	       o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.delivery_id, o.payment_id
+	FROM orders o
+	JOIN unnest($1::text[], $2::timestamp[]) AS k(order_uid, date_created)
+	  ON o.order_uid = k.order_uid AND o.date_created = k.date_created
+	ON CONFLICT (order_uid) DO NOTHING
+	RETURNING order_uid, date_created`
+	rows, err = tx.Query(ctx, copyOrdersQuery, uids, dates)
+	if err != nil {
+		r.sl.Error("Failed to archive orders", "error", err)
+		return nil, len(selected), err
+	}
+	archived, err := pgx.CollectRows(rows, pgx.RowToStructByPos[orderKey])
+	if err != nil {
+		r.sl.Error("Failed to archive orders", "error", err)
+		return nil, len(selected), err
+	}
+	if len(archived) < len(selected) {
+		// Возможно, только если такой UID попал в архив параллельно с выборкой
+		r.sl.Warn("Orders already archived under the same order_uid are kept in place", "skipped", len(selected)-len(archived))
+	}
+	if len(archived) == 0 {
+		return nil, len(selected), tx.Commit(ctx)
+	}
+	uids, dates = splitKeys(archived)
+
+	moveItemsQuery := `
+	WITH moved AS (
+		DELETE FROM items i
+		USING unnest($1::text[], $2::timestamp[]) AS k(order_uid, date_created)
+		WHERE i.order_uid = k.order_uid AND i.order_date_created = k.date_created
+		RETURNING i.*
+	)
+	INSERT INTO items_archive (id, order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
+	SELECT id, order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM moved`
+	if _, err = tx.Exec(ctx, moveItemsQuery, uids, dates); err != nil {
+		r.sl.Error("Failed to archive items", "error", err)
+		return nil, len(selected), err
+	}
+
+	deleteQuery := `
+	DELETE FROM orders o
+	USING unnest($1::text[], $2::timestamp[]) AS k(order_uid, date_created)
+	WHERE o.order_uid = k.order_uid AND o.date_created = k.date_created`
+	if _, err = tx.Exec(ctx, deleteQuery, uids, dates); err != nil {
+		r.sl.Error("Failed to delete archived orders", "error", err)
+		return nil, len(selected), err
+	}
+
+	if err = db.RecordOrderChanges(ctx, tx, uids...); err != nil {
+		r.sl.Error("Failed to notify order changes", "error", err)
+		return nil, len(selected), err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.sl.Error("Failed to commit transaction", "error", err)
+		return nil, len(selected), err
+	}
+	return uids, len(selected), nil
+}
+
+// orderKey — первичный ключ секционированной таблицы orders
+type orderKey struct {
+	OrderUID    string
+	DateCreated time.Time
+}
+
+func splitKeys(keys []orderKey) ([]string, []time.Time) {
+	uids := make([]string, len(keys))
+	dates := make([]time.Time, len(keys))
+	for i, k := range keys {
+		uids[i], dates[i] = k.OrderUID, k.DateCreated
+	}
+	return uids, dates
+}
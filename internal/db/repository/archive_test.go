@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestArchiveOrdersBeforeSkipsArchivedUIDs(t *testing.T) {
+	r := testRepo(t, nil)
+	ctx := context.Background()
+	before := time.Date(2000, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	// Самый старый заказ: его UID уже в архиве, а копия вернулась в основные таблицы
+	dup := testOrder(t)
+	dup.DateCreated = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.SaveOrder(ctx, dup, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.ArchiveOrdersBefore(ctx, dup.DateCreated.Add(time.Second), 1000); err != nil {
+		t.Fatal(err)
+	}
+	cols := `order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, delivery_id, payment_id`
+	if _, err := r.pool.Exec(ctx, `INSERT INTO orders (`+cols+`) SELECT `+cols+` FROM orders_archive WHERE order_uid = $1`, dup.OrderUID); err != nil {
+		t.Fatal(err)
+	}
+
+	order := testOrder(t)
+	order.DateCreated = time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := r.SaveOrder(ctx, order, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Пачка из одного заказа: дубликат не должен занимать её место
+	uids, selected, err := r.ArchiveOrdersBefore(ctx, before, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(uids, order.OrderUID) || selected != 1 {
+		t.Fatalf("ArchiveOrdersBefore = %v, selected %d; want %s archived", uids, selected, order.OrderUID)
+	}
+	uids, selected, err = r.ArchiveOrdersBefore(ctx, before, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uids) != 0 || selected != 0 {
+		t.Fatalf("ArchiveOrdersBefore = %v, selected %d; want nothing left", uids, selected)
+	}
+}
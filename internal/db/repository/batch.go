@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/reconcile"
+	"context"
+	"errors"
+)
+
+// ErrOrderExists — заказ с таким order_uid уже сохранён
+var ErrOrderExists = errors.New("order already exists")
+
+// SaveOrders сохраняет пачку заказов одной транзакцией. Каждый заказ пишется в своей точке сохранения,
+// так что ошибка в одном не отменяет остальные: errs[i] — результат i-го заказа, nil — сохранён.
+// Уже сохранённые заказы (в том числе архивные) не перезаписываются, для них errs[i] = ErrOrderExists.
+// issues[i] — расхождения i-го заказа, сохраняются вместе с ним; issues может быть короче orders.
+// Ошибка err означает, что не сохранился ни один заказ пачки
+func (r *Repo) SaveOrders(ctx context.Context, orders []models.Order, issues [][]reconcile.Issue) (errs []error, err error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		r.sl.Error("Failed to start transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	errs = make([]error, len(orders))
+	for i, order := range orders {
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			r.sl.Error("Failed to create savepoint", "error", err)
+			return nil, err
+		}
+		var orderIssues []reconcile.Issue
+		if i < len(issues) {
+			orderIssues = issues[i]
+		}
+		if errs[i] = r.insertOrder(ctx, sp, order, orderIssues, EventOrderCreated); errs[i] != nil {
+			if err = sp.Rollback(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err = sp.Commit(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.sl.Error("Failed to commit transaction", "error", err)
+		return nil, err
+	}
+	return errs, nil
+}
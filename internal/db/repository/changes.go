@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// ChangedOrderUIDs возвращает UID заказов, изменённых начиная с since, в том числе удалённых
+func (r *Repo) ChangedOrderUIDs(ctx context.Context, since time.Time) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `SELECT order_uid FROM order_changes WHERE changed_at >= $1`, since)
+	if err != nil {
+		r.sl.Error("Failed to retrieve order changes", "error", err)
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// PurgeOrderChanges удаляет из журнала изменения, сделанные раньше before
+func (r *Repo) PurgeOrderChanges(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM order_changes WHERE changed_at < $1`, before)
+	if err != nil {
+		r.sl.Error("Failed to purge order changes", "error", err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
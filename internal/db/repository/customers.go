@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"WBTechL0/internal/db"
+	"WBTechL0/internal/models"
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// GetOrdersByCustomer возвращает все заказы покупателя
+func (r *Repo) GetOrdersByCustomer(ctx context.Context, customerID string) ([]models.Order, error) {
+	uids, err := r.customerOrderUIDs(ctx, r.pool, customerID)
+	if err != nil {
+		r.sl.Error("Failed to retrieve customer orders", "error", err)
+		return nil, err
+	}
+
+	orders := make([]models.Order, 0, len(uids))
+	for _, uid := range uids {
+		order, err := r.GetOrderByUID(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	return orders, nil
+}
+
+// EraseCustomerData обезличивает персональные данные доставки во всех заказах покупателя.
+// Платежи, товары и сами заказы не меняются. Возвращает UID затронутых заказов
+func (r *Repo) EraseCustomerData(ctx context.Context, customerID string) ([]string, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		r.sl.Error("Failed to start transaction", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	uids, err := r.customerOrderUIDs(ctx, tx, customerID)
+	if err != nil {
+		r.sl.Error("Failed to retrieve customer orders", "error", err)
+		return nil, err
+	}
+
+	// Ключ записи удаляется вместе с шифротекстом, так что копии в бэкапах тоже становятся нечитаемыми
+	eraseQuery := `
+	UPDATE deliveries d
+	SET name = $2, phone = $2, zip = $2, address = $2, email = $2,
+	    key_id = NULL, dek = NULL, email_bidx = NULL, phone_bidx = NULL
+	FROM all_orders o
+	WHERE o.delivery_id = d.id AND o.customer_id = $1`
+	if _, err = tx.Exec(ctx, eraseQuery, customerID, models.ErasedValue); err != nil {
+		r.sl.Error("Failed to erase deliveries", "error", err)
+		return nil, err
+	}
+
+	// Имя убираем и из поискового индекса
+	if _, err = tx.Exec(ctx, `UPDATE order_search SET pii_document = NULL, name_tokens = NULL WHERE order_uid = ANY($1)`, uids); err != nil {
+		r.sl.Error("Failed to erase search documents", "error", err)
+		return nil, err
+	}
+
+	if err = db.RecordOrderChanges(ctx, tx, uids...); err != nil {
+		r.sl.Error("Failed to notify order changes", "error", err)
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.sl.Error("Failed to commit transaction", "error", err)
+		return nil, err
+	}
+	return uids, nil
+}
+
+// querier — общее для пула и транзакции
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// execer — общее для пула и транзакции
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func (r *Repo) customerOrderUIDs(ctx context.Context, q querier, customerID string) ([]string, error) {
+	rows, err := q.Query(ctx, `SELECT order_uid FROM all_orders WHERE customer_id = $1 ORDER BY date_created`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
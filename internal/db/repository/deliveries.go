@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"WBTechL0/internal/keyring"
+	"WBTechL0/internal/models"
+	"context"
+	"fmt"
+)
+
+// sealedDelivery — доставка в том виде, в каком она хранится в таблице deliveries
+type sealedDelivery struct {
+	models.Delivery
+	keyID      *string
+	dek        []byte
+	emailIndex []byte
+	phoneIndex []byte
+}
+
+// sealDelivery шифрует персональные данные доставки новым ключом записи.
+// Без файла ключей доставка сохраняется как есть
+func (r *Repo) sealDelivery(d models.Delivery) (sealedDelivery, error) {
+	if r.kr == nil {
+		return sealedDelivery{Delivery: d}, nil
+	}
+
+	env, err := r.kr.NewEnvelope()
+	if err != nil {
+		return sealedDelivery{}, err
+	}
+	sealed, err := sealFields(env, d)
+	if err != nil {
+		return sealedDelivery{}, err
+	}
+
+	return sealedDelivery{
+		Delivery:   sealed,
+		keyID:      &env.KeyID,
+		dek:        env.WrappedKey,
+		emailIndex: r.kr.BlindIndex("email", d.Email),
+		phoneIndex: r.kr.BlindIndex("phone", d.Phone),
+	}, nil
+}
+
+// openDelivery расшифровывает доставку на месте; записи без key_id хранятся в открытом виде
+func (r *Repo) openDelivery(d *models.Delivery, keyID *string, dek []byte) error {
+	if keyID == nil {
+		return nil
+	}
+	if r.kr == nil {
+		return fmt.Errorf("delivery is encrypted with key %q, but no keyring is configured", *keyID)
+	}
+
+	env, err := r.kr.Open(*keyID, dek)
+	if err != nil {
+		return err
+	}
+	for _, f := range deliveryFields(d) {
+		if *f.value, err = env.OpenField(f.name, *f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sealFields(env *keyring.Envelope, d models.Delivery) (models.Delivery, error) {
+	var err error
+	for _, f := range deliveryFields(&d) {
+		if *f.value, err = env.Seal(f.name, *f.value); err != nil {
+			return models.Delivery{}, err
+		}
+	}
+	return d, nil
+}
+
+// deliveryFields перечисляет шифруемые поля доставки
+func deliveryFields(d *models.Delivery) []struct {
+	name  string
+	value *string
+} {
+	return []struct {
+		name  string
+		value *string
+	}{
+		{"name", &d.Name},
+		{"phone", &d.Phone},
+		{"zip", &d.Zip},
+		{"address", &d.Address},
+		{"email", &d.Email},
+	}
+}
+
+// RotateDeliveryKeys приводит таблицу deliveries к текущему основному ключу:
+// открытые записи шифруются, а ключи записей, обёрнутые старыми KEK, перешифровываются.
+// Работает пачками по batchSize строк и возвращает количество обновлённых записей
+func (r *Repo) RotateDeliveryKeys(ctx context.Context, batchSize int) (int, error) {
+	if r.kr == nil {
+		return 0, nil
+	}
+	// При LIMIT 0 пачка всегда пуста, но и меньше batchSize не становится — цикл не завершился бы
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("delivery key rotation batch size must be positive, got %d", batchSize)
+	}
+
+	total := 0
+	for {
+		n, err := r.rotateDeliveryBatch(ctx, batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < batchSize {
+			r.sl.Info("Delivery keys rotated", "primary_key", r.kr.PrimaryID(), "updated", total)
+			return total, nil
+		}
+	}
+}
+
+func (r *Repo) rotateDeliveryBatch(ctx context.Context, batchSize int) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	selectQuery := `
+	SELECT id, name, phone, zip, address, email, key_id, dek
+	FROM deliveries
+	WHERE key_id IS DISTINCT FROM $1
+	ORDER BY id
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, selectQuery, r.kr.PrimaryID(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type row struct {
+		id    int
+		d     models.Delivery
+		keyID *string
+		dek   []byte
+	}
+	var batch []row
+	for rows.Next() {
+		var rw row
+		if err = rows.Scan(&rw.id, &rw.d.Name, &rw.d.Phone, &rw.d.Zip, &rw.d.Address, &rw.d.Email, &rw.keyID, &rw.dek); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, rw)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rewrapQuery := `UPDATE deliveries SET key_id = $2, dek = $3 WHERE id = $1`
+	encryptQuery := `
+	UPDATE deliveries
+	SET name = $2, phone = $3, zip = $4, address = $5, email = $6, key_id = $7, dek = $8, email_bidx = $9, phone_bidx = $10
+	WHERE id = $1`
+
+	for _, rw := range batch {
+		// Открытая запись: шифруем целиком
+		if rw.keyID == nil {
+			sealed, err := r.sealDelivery(rw.d)
+			if err != nil {
+				return 0, err
+			}
+			_, err = tx.Exec(ctx, encryptQuery, rw.id, sealed.Name, sealed.Phone, sealed.Zip, sealed.Address, sealed.Email, sealed.keyID, sealed.dek, sealed.emailIndex, sealed.phoneIndex)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		// Зашифрованная старым ключом: перешифровываем только ключ записи
+		env, err := r.kr.Open(*rw.keyID, rw.dek)
+		if err != nil {
+			return 0, fmt.Errorf("delivery %d: %w", rw.id, err)
+		}
+		if env, err = r.kr.Rewrap(env); err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec(ctx, rewrapQuery, rw.id, env.KeyID, env.WrappedKey); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+// GetOrdersByEmail ищет заказы по email получателя
+func (r *Repo) GetOrdersByEmail(ctx context.Context, email string) ([]models.Order, error) {
+	return r.getOrdersByContact(ctx, "email", email)
+}
+
+// GetOrdersByPhone ищет заказы по телефону получателя
+func (r *Repo) GetOrdersByPhone(ctx context.Context, phone string) ([]models.Order, error) {
+	return r.getOrdersByContact(ctx, "phone", phone)
+}
+
+// plainContacts — как привести открытое значение контакта в бд к виду keyring.Normalize
+var plainContacts = map[string]string{
+	"email": `lower(btrim(d.email))`,
+	"phone": `regexp_replace(d.phone, '[^0-9]', '', 'g')`,
+}
+
+// getOrdersByContact ищет по слепому индексу зашифрованные записи и по значению — открытые.
+// Значение нормализуется одинаково для обоих путей, так что результат не зависит от того, загружены ли ключи
+func (r *Repo) getOrdersByContact(ctx context.Context, kind, value string) ([]models.Order, error) {
+	value = keyring.Normalize(kind, value)
+	if value == "" {
+		return nil, nil
+	}
+	var index []byte
+	if r.kr != nil {
+		index = r.kr.BlindIndex(kind, value)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT o.order_uid
+	FROM all_orders o
+	JOIN deliveries d ON o.delivery_id = d.id
+	WHERE d.%s_bidx = $1 OR (d.key_id IS NULL AND %s = $2)
+	ORDER BY o.date_created DESC`, kind, plainContacts[kind])
+
+	rows, err := r.pool.Query(ctx, query, index, value)
+	if err != nil {
+		r.sl.Error("Failed to search orders by contact", "kind", kind, "error", err)
+		return nil, err
+	}
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err = rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		uids = append(uids, uid)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	orders := make([]models.Order, 0, len(uids))
+	for _, uid := range uids {
+		order, err := r.GetOrderByUID(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	return orders, nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"WBTechL0/internal/keyring"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetOrdersByContact(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		kr   *keyring.Keyring
+	}{
+		{"plaintext", nil},
+		{"encrypted", testKeyring(t)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := testRepo(t, tc.kr)
+			ctx := context.Background()
+			order := testOrder(t)
+			order.Delivery.Email = "Mixed.Case+" + order.OrderUID + "@Example.COM"
+			order.Delivery.Phone = fmt.Sprintf("+7 (900) %03d-%02d-%02d", time.Now().Nanosecond()%1000, len(tc.name), time.Now().Second())
+			if err := r.SaveOrder(ctx, order, nil); err != nil {
+				t.Fatal(err)
+			}
+			digits := keyring.Normalize("phone", order.Delivery.Phone)
+
+			tests := []struct {
+				name, kind, value string
+				want              int
+			}{
+				{"email as stored", "email", order.Delivery.Email, 1},
+				{"email in another case with spaces", "email", "  " + strings.ToLower(order.Delivery.Email) + " ", 1},
+				{"phone as stored", "phone", order.Delivery.Phone, 1},
+				{"phone digits only", "phone", digits, 1},
+				{"other email", "email", "nobody-" + order.OrderUID + "@example.com", 0},
+				{"empty phone", "phone", " - ", 0},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					get := r.GetOrdersByEmail
+					if tt.kind == "phone" {
+						get = r.GetOrdersByPhone
+					}
+					orders, err := get(ctx, tt.value)
+					if err != nil {
+						t.Fatal(err)
+					}
+					found := 0
+					for _, o := range orders {
+						if o.OrderUID == order.OrderUID {
+							found++
+						}
+					}
+					if found != tt.want {
+						t.Fatalf("found the order %d times, want %d", found, tt.want)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"WBTechL0/internal/models"
+	"context"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// OrderFilter — отбор заказов для выгрузки; пустые поля не ограничивают выборку
+type OrderFilter struct {
+	From            time.Time // date_created >= From
+	To              time.Time // date_created < To
+	CustomerID      string
+	DeliveryService string
+}
+
+// StreamOrders передаёт в fn заказы (основные и архивные), подходящие под filter, в порядке date_created.
+// Заказы читаются одним запросом и собираются по одному, так что в памяти держится только текущий.
+// Персональные данные доставки, кроме города и региона, не читаются.
+// Выгрузка может идти дольше statement_timeout пула, поэтому он отключается на время запроса; прервать её можно через ctx
+func (r *Repo) StreamOrders(ctx context.Context, filter OrderFilter, fn func(models.Order) error) error {
+	query := `
+	SELECT o.order_uid, o.track_number, o.entry, o.locale, o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+	       d.city, d.region,
+	       p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
+	       i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+	FROM all_orders o
+	JOIN deliveries d ON d.id = o.delivery_id
+	JOIN payments p ON p.id = o.payment_id
+	LEFT JOIN all_items i ON i.order_uid = o.order_uid AND i.order_date_created = o.date_created
+	WHERE ($1::timestamp IS NULL OR o.date_created >= $1)
+	  AND ($2::timestamp IS NULL OR o.date_created < $2)
+	  AND ($3 = '' OR o.customer_id = $3)
+	  AND ($4 = '' OR o.delivery_service = $4)
+	ORDER BY o.date_created, o.order_uid, i.id`
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		r.sl.Error("Failed to start transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err = tx.Exec(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		r.sl.Error("Failed to disable statement timeout", "error", err)
+		return err
+	}
+
+	rows, err := tx.Query(ctx, query, optionalTime(filter.From), optionalTime(filter.To), filter.CustomerID, filter.DeliveryService)
+	if err != nil {
+		r.sl.Error("Failed to stream orders", "error", err)
+		return err
+	}
+	defer rows.Close()
+
+	var current *models.Order
+	for rows.Next() {
+		var order models.Order
+		var item struct {
+			ChrtID, Price, Sale, TotalPrice, NmID, Status *int
+			TrackNumber, Rid, Name, Size, Brand           *string
+		}
+		err = rows.Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.CustomerID, &order.DeliveryService,
+			&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard,
+			&order.Delivery.City, &order.Delivery.Region,
+			&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency, &order.Payment.Provider, &order.Payment.Amount,
+			&order.Payment.PaymentDT, &order.Payment.Bank, &order.Payment.DeliveryCost, &order.Payment.GoodsTotal, &order.Payment.CustomFee,
+			&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status,
+		)
+		if err != nil {
+			r.sl.Error("Failed to scan order", "error", err)
+			return err
+		}
+
+		// Строки одного заказа идут подряд: новый order_uid — предыдущий заказ собран
+		if current == nil || current.OrderUID != order.OrderUID {
+			if current != nil {
+				if err = fn(*current); err != nil {
+					return err
+				}
+			}
+			current = &order
+		}
+		if item.ChrtID != nil {
+			current.Items = append(current.Items, models.Item{
+				ChrtID: *item.ChrtID, TrackNumber: deref(item.TrackNumber), Price: *item.Price, Rid: *item.Rid, Name: *item.Name,
+				Sale: deref(item.Sale), Size: *item.Size, TotalPrice: *item.TotalPrice, NmID: *item.NmID, Brand: *item.Brand, Status: *item.Status,
+			})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		r.sl.Error("Error during rows iteration", "error", err)
+		return err
+	}
+
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
+
+// optionalTime — NULL вместо нулевого времени
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
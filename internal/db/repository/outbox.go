@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/redact"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// Типы событий, которые сервис публикует о сохранённых заказах. Те же типы принимает консюмер
+// (consumer.EventOrderCreated, consumer.EventOrderUpdated), так что события одного сервиса читаются другим
+const (
+	EventOrderCreated = "order.created" // Новый заказ
+	EventOrderUpdated = "order.updated" // Новая версия заказа, заменяет сохранённую
+)
+
+// OutboxEvent — событие из таблицы outbox, ожидающее публикации
+type OutboxEvent struct {
+	ID        int64
+	OrderUID  string
+	EventType string
+	Payload   []byte // Заказ в JSON с замаскированными персональными данными
+	CreatedAt time.Time
+}
+
+// enqueueEvent записывает событие о заказе в outbox в той же транзакции, что и сам заказ,
+// так что событие публикуется тогда и только тогда, когда заказ сохранён.
+// Персональные данные в событие не попадают: outbox не шифруется, а события читают другие сервисы
+func (r *Repo) enqueueEvent(ctx context.Context, tx pgx.Tx, eventType string, order models.Order) error {
+	payload, err := json.Marshal(redact.Mask(order))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO outbox (order_uid, event_type, payload) VALUES ($1, $2, $3)`, order.OrderUID, eventType, payload)
+	return err
+}
+
+// RelayOutbox передаёт в publish до limit неопубликованных событий в порядке записи и отмечает их
+// опубликованными, если publish успешен. События блокируются на время публикации, поэтому несколько
+// реплик могут публиковать параллельно, не дублируя друг друга. Возвращает количество опубликованных
+func (r *Repo) RelayOutbox(ctx context.Context, limit int, publish func([]OutboxEvent) error) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		r.sl.Error("Failed to start transaction", "error", err)
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+	SELECT id, order_uid, event_type, payload, created_at
+	FROM outbox
+	WHERE published_at IS NULL
+	ORDER BY id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		r.sl.Error("Failed to read outbox", "error", err)
+		return 0, err
+	}
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByPos[OutboxEvent])
+	if err != nil {
+		r.sl.Error("Failed to scan outbox event", "error", err)
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err = publish(events); err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	if _, err = tx.Exec(ctx, `UPDATE outbox SET published_at = now() WHERE id = ANY($1)`, ids); err != nil {
+		r.sl.Error("Failed to mark outbox events published", "error", err)
+		return 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		r.sl.Error("Failed to commit transaction", "error", err)
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// PurgeOutbox удаляет события, опубликованные раньше before
+func (r *Repo) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		r.sl.Error("Failed to purge outbox", "error", err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
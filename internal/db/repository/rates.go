@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"WBTechL0/internal/money"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"math/big"
+	"time"
+)
+
+// ErrRateNotFound — нет курса валюты на нужную дату
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// UpsertExchangeRates сохраняет курсы; курс на ту же дату перезаписывается
+func (r *Repo) UpsertExchangeRates(ctx context.Context, rates []money.Rate) error {
+	query := `
+	INSERT INTO exchange_rates (currency, valid_from, rate)
+	VALUES ($1, $2, $3::numeric)
+	ON CONFLICT (currency, valid_from) DO UPDATE SET rate = EXCLUDED.rate`
+
+	batch := &pgx.Batch{}
+	for _, rate := range rates {
+		batch.Queue(query, rate.Currency, rate.ValidFrom, rate.Rate.FloatString(10))
+	}
+	if err := r.pool.SendBatch(ctx, batch).Close(); err != nil {
+		r.sl.Error("Failed to save exchange rates", "error", err)
+		return err
+	}
+	return nil
+}
+
+// GetExchangeRate возвращает последний курс валюты к базовой, действующий на момент at
+func (r *Repo) GetExchangeRate(ctx context.Context, currency string, at time.Time) (*big.Rat, error) {
+	query := `
+	SELECT rate::text
+	FROM exchange_rates
+	WHERE currency = $1 AND valid_from <= $2
+	ORDER BY valid_from DESC
+	LIMIT 1`
+
+	var raw string
+	err := r.pool.QueryRow(ctx, query, currency, at).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s on %s", ErrRateNotFound, currency, at.Format(time.DateOnly))
+	}
+	if err != nil {
+		r.sl.Error("Failed to retrieve exchange rate", "currency", currency, "error", err)
+		return nil, err
+	}
+
+	rate, ok := new(big.Rat).SetString(raw)
+	if !ok {
+		return nil, fmt.Errorf("invalid exchange rate %q for %s", raw, currency)
+	}
+	return rate, nil
+}
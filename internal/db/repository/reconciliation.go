@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"WBTechL0/internal/reconcile"
+	"context"
+	"time"
+)
+
+// FlaggedOrder — заказ с расхождениями в суммах
+type FlaggedOrder struct {
+	OrderUID   string            `json:"order_uid"`
+	DetectedAt time.Time         `json:"detected_at"`
+	Issues     []reconcile.Issue `json:"issues"`
+}
+
+// saveReconciliationIssues сохраняет расхождения, найденные в заказе, в транзакции, которой он записывается
+func (r *Repo) saveReconciliationIssues(ctx context.Context, tx execer, orderUID string, issues []reconcile.Issue) error {
+	query := `
+	INSERT INTO reconciliation_issues (order_uid, check_name, item, expected, actual)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	for _, issue := range issues {
+		if _, err := tx.Exec(ctx, query, orderUID, issue.Check, issue.Item, issue.Expected, issue.Actual); err != nil {
+			r.sl.Error("Failed to save reconciliation issue", "order_uid", orderUID, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReconciliationIssues возвращает до limit заказов с расхождениями, начиная с последних обнаруженных
+func (r *Repo) GetReconciliationIssues(ctx context.Context, limit int) ([]FlaggedOrder, error) {
+	query := `
+	WITH flagged AS (
+		SELECT order_uid, max(detected_at) AS detected_at
+		FROM reconciliation_issues
+		GROUP BY order_uid
+		ORDER BY detected_at DESC
+		LIMIT $1
+	)
+	SELECT f.order_uid, f.detected_at, i.check_name, i.item, i.expected, i.actual
+	FROM flagged f
+	JOIN reconciliation_issues i ON i.order_uid = f.order_uid
+	ORDER BY f.detected_at DESC, f.order_uid, i.id`
+
+	rows, err := r.pool.Query(ctx, query, limit)
+	if err != nil {
+		r.sl.Error("Failed to retrieve reconciliation issues", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []FlaggedOrder
+	for rows.Next() {
+		var uid string
+		var detectedAt time.Time
+		var issue reconcile.Issue
+		if err = rows.Scan(&uid, &detectedAt, &issue.Check, &issue.Item, &issue.Expected, &issue.Actual); err != nil {
+			r.sl.Error("Failed to scan reconciliation issue", "error", err)
+			return nil, err
+		}
+		if n := len(orders); n == 0 || orders[n-1].OrderUID != uid {
+			orders = append(orders, FlaggedOrder{OrderUID: uid, DetectedAt: detectedAt})
+		}
+		last := &orders[len(orders)-1]
+		last.Issues = append(last.Issues, issue)
+	}
+	if err = rows.Err(); err != nil {
+		r.sl.Error("Error during rows iteration", "error", err)
+		return nil, err
+	}
+	return orders, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/reconcile"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReconciliationIssuesSavedWithOrder(t *testing.T) {
+	r := testRepo(t, nil)
+	ctx := context.Background()
+	issues := []reconcile.Issue{{Check: "payment_amount", Expected: 100, Actual: 90}}
+
+	order := testOrder(t)
+	if err := r.SaveOrder(ctx, order, issues); err != nil {
+		t.Fatal(err)
+	}
+	// Повторная запись отклоняется вместе со своими расхождениями
+	if err := r.SaveOrder(ctx, order, issues); !errors.Is(err, ErrOrderExists) {
+		t.Fatalf("SaveOrder duplicate = %v, want ErrOrderExists", err)
+	}
+	if got := countIssues(t, r, order.OrderUID); got != 1 {
+		t.Fatalf("issues after SaveOrder = %d, want 1", got)
+	}
+
+	// Замена заказа заменяет и расхождения
+	order.Entry += "-v2"
+	if _, err := r.UpsertOrder(ctx, order, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := countIssues(t, r, order.OrderUID); got != 0 {
+		t.Fatalf("issues after UpsertOrder = %d, want 0", got)
+	}
+
+	batch := []models.Order{testOrder(t), testOrder(t)}
+	errs, err := r.SaveOrders(ctx, batch, [][]reconcile.Issue{nil, issues})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{0, 1} {
+		if errs[i] != nil {
+			t.Fatalf("SaveOrders errs[%d] = %v", i, errs[i])
+		}
+		if got := countIssues(t, r, batch[i].OrderUID); got != want {
+			t.Fatalf("issues of batch[%d] = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func countIssues(t *testing.T, r *Repo, uid string) int {
+	t.Helper()
+	var n int
+	if err := r.pool.QueryRow(context.Background(), `SELECT count(*) FROM reconciliation_issues WHERE order_uid = $1`, uid).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -1,41 +1,83 @@
 package repository
 
 import (
+	"WBTechL0/internal/db"
+	"WBTechL0/internal/keyring"
 	"WBTechL0/internal/models"
+	"WBTechL0/internal/money"
+	"WBTechL0/internal/reconcile"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"log/slog"
+	"time"
 )
 
 type Repo struct {
 	pool *pgxpool.Pool
 	sl   *slog.Logger
+	kr   *keyring.Keyring // nil — персональные данные доставки хранятся в открытом виде
 }
 
 // New создаёт новый Repo
-func New(pool *pgxpool.Pool, sl *slog.Logger) *Repo {
-	return &Repo{pool: pool, sl: sl}
+func New(pool *pgxpool.Pool, sl *slog.Logger, kr *keyring.Keyring) *Repo {
+	return &Repo{pool: pool, sl: sl, kr: kr}
 }
 
-// SaveOrder Сохраняет ордер в базу данных
-func (r *Repo) SaveOrder(ctx context.Context, order models.Order) error {
+// SaveOrder Сохраняет ордер в базу данных вместе с найденными в нём расхождениями issues
+func (r *Repo) SaveOrder(ctx context.Context, order models.Order, issues []reconcile.Issue) error {
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		r.sl.Error("Failed to start transaction", err)
+		r.sl.Error("Failed to start transaction", "error", err)
 		return err
 	}
 	defer tx.Rollback(ctx)
 
+	if err = r.insertOrder(ctx, tx, order, issues, EventOrderCreated); err != nil {
+		if errors.Is(err, ErrOrderExists) {
+			r.sl.Warn("Order already exists", "order_uid", order.OrderUID)
+		}
+		return err
+	}
+
+	// Коммит транзакции
+	err = tx.Commit(ctx)
+	if err != nil {
+		r.sl.Error("Failed to commit transaction", "error", err)
+		return err
+	}
+
+	r.sl.Info("Order successfully saved", "order_uid", order.OrderUID)
+	return nil
+}
+
+// insertOrder записывает заказ со всеми связанными данными, расхождения issues и событие event о нём в транзакции tx.
+// Первичный ключ секционированной orders включает date_created, поэтому уникальность order_uid
+// (вместе с архивом) проверяется здесь под блокировкой заказа; если заказ уже есть — ErrOrderExists
+func (r *Repo) insertOrder(ctx context.Context, tx pgx.Tx, order models.Order, issues []reconcile.Issue, event string) error {
+	exists, err := lockOrder(ctx, tx, order.OrderUID)
+	if err != nil {
+		r.sl.Error("Failed to lock order", "order_uid", order.OrderUID, "error", err)
+		return err
+	}
+	if exists {
+		return ErrOrderExists
+	}
+
 	// Сохраняем доставку
 	var deliveryID int
-	deliveryQuery := `INSERT INTO deliveries (name, phone, zip, city, address, region, email) 
-	                  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
-	err = tx.QueryRow(ctx, deliveryQuery, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email).Scan(&deliveryID)
+	sealed, err := r.sealDelivery(order.Delivery)
 	if err != nil {
-		r.sl.Error("Failed to insert delivery", err)
+		r.sl.Error("Failed to encrypt delivery", "error", err)
+		return err
+	}
+	deliveryQuery := `INSERT INTO deliveries (name, phone, zip, city, address, region, email, key_id, dek, email_bidx, phone_bidx) 
+	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+	err = tx.QueryRow(ctx, deliveryQuery, sealed.Name, sealed.Phone, sealed.Zip, sealed.City, sealed.Address, sealed.Region, sealed.Email, sealed.keyID, sealed.dek, sealed.emailIndex, sealed.phoneIndex).Scan(&deliveryID)
+	if err != nil {
+		r.sl.Error("Failed to insert delivery", "error", err)
 		return err
 	}
 
@@ -43,9 +85,9 @@ func (r *Repo) SaveOrder(ctx context.Context, order models.Order) error {
 	var paymentID int
 	paymentQuery := `INSERT INTO payments (transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) 
 	                 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
-	err = tx.QueryRow(ctx, paymentQuery, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee).Scan(&paymentID)
+	err = tx.QueryRow(ctx, paymentQuery, order.Payment.Transaction, order.Payment.RequestID, money.NormalizeCode(order.Payment.Currency), order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee).Scan(&paymentID)
 	if err != nil {
-		r.sl.Error("Failed to insert payment", err)
+		r.sl.Error("Failed to insert payment", "error", err)
 		return err
 	}
 
@@ -54,40 +96,73 @@ func (r *Repo) SaveOrder(ctx context.Context, order models.Order) error {
 	               VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 	_, err = tx.Exec(ctx, orderQuery, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard, deliveryID, paymentID)
 	if err != nil {
-		r.sl.Error("Failed to insert order", err)
+		r.sl.Error("Failed to insert order", "error", err)
 		return err
 	}
 
 	// Сохраняем товары
-	itemQuery := `INSERT INTO items (chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status) 
-	              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	itemQuery := `INSERT INTO items (order_uid, order_date_created, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status) 
+	              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 	for _, item := range order.Items {
-		_, err := tx.Exec(ctx, itemQuery, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
+		_, err := tx.Exec(ctx, itemQuery, order.OrderUID, order.DateCreated, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
 		if err != nil {
-			r.sl.Error("Failed to insert item", err)
+			r.sl.Error("Failed to insert item", "error", err)
 			return err
 		}
 	}
 
-	// Коммит транзакции
-	err = tx.Commit(ctx)
-	if err != nil {
-		r.sl.Error("Failed to commit transaction", err)
+	// Расхождения пишутся вместе с заказом: сохранённый заказ не останется без отметки
+	if err = r.saveReconciliationIssues(ctx, tx, order.OrderUID, issues); err != nil {
+		return err
+	}
+
+	// Обновляем поисковый документ
+	if err = r.indexOrder(ctx, tx, order); err != nil {
+		r.sl.Error("Failed to index order for search", "error", err)
 		return err
 	}
 
-	r.sl.Info("Order successfully saved", "order", order)
+	// Событие для других сервисов публикуется из outbox после коммита
+	if err = r.enqueueEvent(ctx, tx, event, order); err != nil {
+		r.sl.Error("Failed to write outbox event", "error", err)
+		return err
+	}
+
+	// Другие экземпляры сбросят заказ из своих кэшей после коммита
+	if err = db.RecordOrderChanges(ctx, tx, order.OrderUID); err != nil {
+		r.sl.Error("Failed to notify order change", "error", err)
+		return err
+	}
 	return nil
 }
 
+// lockOrder берёт блокировку order_uid до конца транзакции и сообщает, сохранён ли уже такой заказ.
+// Блокировка сериализует конкурирующие записи одного заказа, даже если его ещё нет в таблицах
+func lockOrder(ctx context.Context, tx pgx.Tx, orderUID string) (bool, error) {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, orderUID); err != nil {
+		return false, err
+	}
+	var exists bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM all_orders WHERE order_uid = $1)`, orderUID).Scan(&exists)
+	return exists, err
+}
+
+// ErrOrderNotFound — заказа с таким order_uid нет ни в основных, ни в архивных таблицах
+var ErrOrderNotFound = errors.New("order not found")
+
 // GetOrderByUID получает заказ по order_uid
 func (r *Repo) GetOrderByUID(ctx context.Context, orderUID string) (*models.Order, error) {
-	// Запрос для получения заказа и связанных данных (delivery, payment)
+	return r.getOrder(ctx, r.pool, orderUID)
+}
+
+// getOrder читает заказ через q — пул или транзакцию, в которой заказ заблокирован
+func (r *Repo) getOrder(ctx context.Context, q querier, orderUID string) (*models.Order, error) {
+	// Запрос для получения заказа и связанных данных (delivery, payment); заказ может лежать и в архиве
 	orderQuery := `
 	SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
-	       d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+	       d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.key_id, d.dek,
 	       p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
-	FROM orders o
+	FROM all_orders o
 	JOIN deliveries d ON o.delivery_id = d.id
 	JOIN payments p ON o.payment_id = p.id
 	WHERE o.order_uid = $1`
@@ -95,35 +170,42 @@ func (r *Repo) GetOrderByUID(ctx context.Context, orderUID string) (*models.Orde
 	var order models.Order
 	var delivery models.Delivery
 	var payment models.Payment
+	var keyID *string
+	var dek []byte
 
-	err := r.pool.QueryRow(ctx, orderQuery, orderUID).Scan(
+	err := q.QueryRow(ctx, orderQuery, orderUID).Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature, &order.CustomerID, &order.DeliveryService,
 		&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard,
-		&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email,
+		&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email, &keyID, &dek,
 		&payment.Transaction, &payment.RequestID, &payment.Currency, &payment.Provider, &payment.Amount, &payment.PaymentDT,
 		&payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			r.sl.Warn("Order not found", "order_uid", orderUID)
-			return nil, errors.New("order not found")
+			return nil, ErrOrderNotFound
 		}
-		r.sl.Error("Failed to retrieve order", err)
+		r.sl.Error("Failed to retrieve order", "error", err)
 		return nil, err
 	}
 
+	if err = r.openDelivery(&delivery, keyID, dek); err != nil {
+		r.sl.Error("Failed to decrypt delivery", "order_uid", orderUID, "error", err)
+		return nil, err
+	}
 	order.Delivery = delivery
 	order.Payment = payment
 
 	// Запрос для получения товаров, связанных с заказом
 	itemsQuery := `
 	SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-	FROM items
-	WHERE track_number = $1`
+	FROM all_items
+	WHERE order_uid = $1
+	ORDER BY id`
 
-	rows, err := r.pool.Query(ctx, itemsQuery, order.TrackNumber)
+	rows, err := q.Query(ctx, itemsQuery, order.OrderUID)
 	if err != nil {
-		r.sl.Error("Failed to retrieve items", err)
+		r.sl.Error("Failed to retrieve items", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -132,95 +214,138 @@ func (r *Repo) GetOrderByUID(ctx context.Context, orderUID string) (*models.Orde
 	for rows.Next() {
 		var item models.Item
 		if err = rows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status); err != nil {
-			r.sl.Error("Failed to scan item", err)
+			r.sl.Error("Failed to scan item", "error", err)
 			return nil, err
 		}
 		items = append(items, item)
 	}
 
 	if err = rows.Err(); err != nil {
-		r.sl.Error("Error during rows iteration", err)
+		r.sl.Error("Error during rows iteration", "error", err)
 		return nil, err
 	}
 
 	order.Items = items
 
-	r.sl.Debug("Order retrieved successfully", "order_uid", orderUID, "order", order)
+	r.sl.Debug("Order retrieved successfully", "order_uid", orderUID)
 	return &order, nil
 }
 
-// GetAllOrders получает все заказы из базы данных
-func (r *Repo) GetAllOrders(ctx context.Context) ([]models.Order, error) {
-	// Запрос для получения всех заказов и связанных данных (доставка, платеж)
+// PageCursor — позиция в выдаче GetOrdersPage: последний заказ предыдущей страницы
+type PageCursor struct {
+	DateCreated time.Time
+	OrderUID    string
+}
+
+// GetOrdersPage получает до limit заказов основных таблиц, от новых к старым, начиная после after
+// (nil — с самого нового). Товары страницы читаются одним запросом
+func (r *Repo) GetOrdersPage(ctx context.Context, after *PageCursor, limit int) ([]models.Order, error) {
+	var afterDate *time.Time
+	var afterUID *string
+	if after != nil {
+		afterDate, afterUID = &after.DateCreated, &after.OrderUID
+	}
+
 	orderQuery := `
 	SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
-	       d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+	       d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.key_id, d.dek,
 	       p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
 	FROM orders o
 	JOIN deliveries d ON o.delivery_id = d.id
-	JOIN payments p ON o.payment_id = p.id`
+	JOIN payments p ON o.payment_id = p.id
+	WHERE $1::timestamp IS NULL OR (o.date_created, o.order_uid) < ($1, $2)
+	ORDER BY o.date_created DESC, o.order_uid DESC
+	LIMIT $3`
 
-	rows, err := r.pool.Query(ctx, orderQuery)
+	rows, err := r.pool.Query(ctx, orderQuery, afterDate, afterUID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve orders from database: %w", err)
 	}
 	defer rows.Close()
 
 	var orders []models.Order
+	index := make(map[string]int)
 	for rows.Next() {
 		var order models.Order
 		var delivery models.Delivery
 		var payment models.Payment
+		var keyID *string
+		var dek []byte
 
 		// Сканируем данные заказа, доставки и платежа
 		err = rows.Scan(
 			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature, &order.CustomerID, &order.DeliveryService,
 			&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard,
-			&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email,
+			&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email, &keyID, &dek,
 			&payment.Transaction, &payment.RequestID, &payment.Currency, &payment.Provider, &payment.Amount, &payment.PaymentDT,
 			&payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee,
 		)
 		if err != nil {
-			r.sl.Error("Failed to scan order", err)
+			r.sl.Error("Failed to scan order", "error", err)
 			return nil, err
 		}
 
-		order.Delivery = delivery
-		order.Payment = payment
-
-		// Получаем товары для каждого заказа
-		itemsQuery := `
-		SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		FROM items
-		WHERE track_number = $1`
-
-		itemRows, err := r.pool.Query(ctx, itemsQuery, order.TrackNumber)
-		if err != nil {
-			r.sl.Error("Failed to retrieve items for order", "order_uid", order.OrderUID, err)
+		if err = r.openDelivery(&delivery, keyID, dek); err != nil {
+			r.sl.Error("Failed to decrypt delivery", "order_uid", order.OrderUID, "error", err)
 			return nil, err
 		}
+		order.Delivery = delivery
+		order.Payment = payment
 
-		var items []models.Item
-		for itemRows.Next() {
-			var item models.Item
-			if err := itemRows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status); err != nil {
-				r.sl.Error("Failed to scan item", err)
-				itemRows.Close()
-				return nil, err
-			}
-			items = append(items, item)
-		}
-		itemRows.Close()
-
-		order.Items = items
+		index[order.OrderUID] = len(orders)
 		orders = append(orders, order)
 	}
+	if err = rows.Err(); err != nil {
+		r.sl.Error("Error during rows iteration", "error", err)
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
 
-	if rows.Err() != nil {
-		r.sl.Error("Error during rows iteration", err)
+	// Получаем товары всех заказов страницы
+	uids := make([]string, len(orders))
+	dates := make([]time.Time, len(orders))
+	for i, order := range orders {
+		uids[i], dates[i] = order.OrderUID, order.DateCreated
+	}
+	itemsQuery := `
+	SELECT i.order_uid, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+	FROM items i
+	JOIN unnest($1::text[], $2::timestamp[]) AS page(order_uid, date_created)
+	  ON i.order_uid = page.order_uid AND i.order_date_created = page.date_created
+	ORDER BY i.id`
+
+	itemRows, err := r.pool.Query(ctx, itemsQuery, uids, dates)
+	if err != nil {
+		r.sl.Error("Failed to retrieve items for orders", "error", err)
 		return nil, err
 	}
+	defer itemRows.Close()
 
-	r.sl.Debug("All orders retrieved successfully")
+	for itemRows.Next() {
+		var uid string
+		var item models.Item
+		if err = itemRows.Scan(&uid, &item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status); err != nil {
+			r.sl.Error("Failed to scan item", "error", err)
+			return nil, err
+		}
+		order := &orders[index[uid]]
+		order.Items = append(order.Items, item)
+	}
+	if err = itemRows.Err(); err != nil {
+		r.sl.Error("Error during rows iteration", "error", err)
+		return nil, err
+	}
 	return orders, nil
 }
+
+// CountOrders возвращает количество заказов в основных таблицах
+func (r *Repo) CountOrders(ctx context.Context) (int, error) {
+	var n int
+	if err := r.pool.QueryRow(ctx, `SELECT count(*) FROM orders`).Scan(&n); err != nil {
+		r.sl.Error("Failed to count orders", "error", err)
+		return 0, err
+	}
+	return n, nil
+}
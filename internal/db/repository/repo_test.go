@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"WBTechL0/internal/db"
+	"WBTechL0/internal/keyring"
+	"WBTechL0/internal/models"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testRepo подключается к тестовой базе из TEST_DATABASE_URL и создаёт таблицы; без неё тест пропускается
+func testRepo(t *testing.T, kr *keyring.Keyring) *Repo {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	sl := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err = db.CreateTables(pool, sl); err != nil {
+		t.Fatal(err)
+	}
+	return New(pool, sl, kr)
+}
+
+// testKeyring создаёт файл ключей со случайными ключами и загружает его
+func testKeyring(t *testing.T) *keyring.Keyring {
+	t.Helper()
+	key := func() string {
+		b := make([]byte, 32)
+		_, _ = rand.Read(b)
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	data := fmt.Sprintf(`{"primary":"k1","keys":[{"id":"k1","key":%q}],"blindIndexKey":%q}`, key(), key())
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	kr, err := keyring.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+// testOrder — согласованный заказ с уникальным order_uid
+func testOrder(t *testing.T) models.Order {
+	t.Helper()
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	uid := "test" + hex.EncodeToString(b)
+	return models.Order{
+		OrderUID:    uid,
+		TrackNumber: "TRACK" + uid,
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: uid + "@example.com",
+		},
+		Payment: models.Payment{
+			Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817, PaymentDT: 1637907727,
+			Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []models.Item{{
+			ChrtID: 9934930, TrackNumber: "TRACK" + uid, Price: 453, Rid: "rid" + uid, Name: "Mascaras",
+			Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale: "en", CustomerID: "customer" + uid, DeliveryService: "meest", Shardkey: "9", SmID: 99,
+		DateCreated: time.Now().UTC().Truncate(time.Microsecond), OofShard: "1",
+	}
+}
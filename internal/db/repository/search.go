@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/search"
+	"context"
+	"github.com/jackc/pgx/v5"
+	"strings"
+)
+
+// SearchHit — найденный заказ и его релевантность
+type SearchHit struct {
+	OrderUID string
+	Rank     float64
+}
+
+// Поисковый документ заказа хранится в order_search:
+//   - document — товары и бренды (вес B), город и регион (C), номера и служба доставки (D);
+//   - pii_document — имя получателя (вес A), если данные доставки не шифруются;
+//   - name_tokens — слепые индексы слов имени, если шифруются: по ним находится только точное слово.
+//
+// Имя не попадает в tsvector в открытом виде, иначе шифрование доставки теряет смысл
+const indexOrderQuery = `
+	INSERT INTO order_search (order_uid, date_created, document, pii_document, name_tokens)
+	VALUES ($1, $2,
+	        setweight(to_tsvector('simple', $3), 'B') ||
+	        setweight(to_tsvector('simple', $4), 'C') ||
+	        setweight(to_tsvector('simple', $5), 'D'),
+	        setweight(to_tsvector('simple', $6::text), 'A'),
+	        $7)
+	ON CONFLICT (order_uid) DO UPDATE
+	SET date_created = EXCLUDED.date_created, document = EXCLUDED.document,
+	    pii_document = EXCLUDED.pii_document, name_tokens = EXCLUDED.name_tokens`
+
+// indexOrder обновляет поисковый документ заказа; вызывается в транзакции сохранения
+func (r *Repo) indexOrder(ctx context.Context, q execer, order models.Order) error {
+	goods := make([]string, 0, 2*len(order.Items))
+	for _, item := range order.Items {
+		goods = append(goods, item.Name, item.Brand)
+	}
+	place := order.Delivery.City + " " + order.Delivery.Region
+	ids := strings.Join([]string{order.OrderUID, order.TrackNumber, order.CustomerID, order.DeliveryService}, " ")
+
+	var name *string
+	var tokens [][]byte
+	switch {
+	case order.Delivery.Name == models.ErasedValue:
+	case r.kr == nil:
+		name = &order.Delivery.Name
+	default:
+		for _, t := range search.Terms(order.Delivery.Name) {
+			tokens = append(tokens, r.kr.BlindIndex("name", t))
+		}
+	}
+
+	_, err := q.Exec(ctx, indexOrderQuery, order.OrderUID, order.DateCreated, strings.Join(goods, " "), place, ids, name, tokens)
+	return err
+}
+
+// SearchOrders ищет заказы по словам запроса, самые релевантные первыми.
+// Без includePII имя получателя в поиске не участвует
+func (r *Repo) SearchOrders(ctx context.Context, query string, includePII bool, limit int) ([]SearchHit, error) {
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var tokens [][]byte
+	if includePII && r.kr != nil {
+		for _, t := range terms {
+			tokens = append(tokens, r.kr.BlindIndex("name", t))
+		}
+	}
+
+	// Совпадение по слепому индексу имени весит как совпадение с весом A
+	searchQuery := `
+	SELECT s.order_uid,
+	       ts_rank(s.document, q) +
+	       CASE WHEN $2 THEN ts_rank(coalesce(s.pii_document, ''), q) ELSE 0 END +
+	       CASE WHEN s.name_tokens && $3 THEN 1 ELSE 0 END AS rank
+	FROM order_search s, to_tsquery('simple', $1) q
+	WHERE s.document @@ q
+	   OR ($2 AND s.pii_document @@ q)
+	   OR s.name_tokens && $3
+	ORDER BY rank DESC, s.date_created DESC
+	LIMIT $4`
+
+	rows, err := r.pool.Query(ctx, searchQuery, search.TSQuery(terms), includePII, tokens, limit)
+	if err != nil {
+		r.sl.Error("Failed to search orders", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []SearchHit
+	for rows.Next() {
+		var hit SearchHit
+		if err = rows.Scan(&hit.OrderUID, &hit.Rank); err != nil {
+			r.sl.Error("Failed to scan search hit", "error", err)
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		r.sl.Error("Error during rows iteration", "error", err)
+		return nil, err
+	}
+	return hits, nil
+}
+
+// IndexMissingOrders строит поисковые документы заказов, сохранённых до появления поиска,
+// и перестраивает документы, не соответствующие текущему режиму шифрования. Возвращает число заказов
+func (r *Repo) IndexMissingOrders(ctx context.Context) (int, error) {
+	staleQuery := `
+	SELECT o.order_uid
+	FROM all_orders o
+	LEFT JOIN order_search s ON s.order_uid = o.order_uid
+	WHERE s.order_uid IS NULL
+	   OR ($1 AND s.pii_document IS NOT NULL)
+	   OR (NOT $1 AND s.name_tokens IS NOT NULL)`
+
+	rows, err := r.pool.Query(ctx, staleQuery, r.kr != nil)
+	if err != nil {
+		return 0, err
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	for i, uid := range uids {
+		order, err := r.GetOrderByUID(ctx, uid)
+		if err != nil {
+			return i, err
+		}
+		if err = r.indexOrder(ctx, r.pool, *order); err != nil {
+			return i, err
+		}
+	}
+	return len(uids), nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/reconcile"
+	"context"
+	"reflect"
+	"time"
+)
+
+// UpsertResult — что сделал UpsertOrder
+type UpsertResult int
+
+const (
+	Inserted  UpsertResult = iota // Заказа не было
+	Updated                       // Заказ был и отличался; записан заново
+	Unchanged                     // Заказ был и совпадает
+)
+
+func (u UpsertResult) String() string {
+	switch u {
+	case Inserted:
+		return "inserted"
+	case Updated:
+		return "updated"
+	}
+	return "unchanged"
+}
+
+// UpsertOrder сохраняет заказ, заменяя сохранённый с тем же order_uid, если тот отличается.
+// Повторный вызов с тем же заказом ничего не меняет. Если персональные данные покупателя были удалены
+// по запросу, они остаются удалёнными. Архивный заказ при замене возвращается в основные таблицы.
+// Расхождения issues заменяют сохранённые для заказа в той же транзакции
+func (r *Repo) UpsertOrder(ctx context.Context, order models.Order, issues []reconcile.Issue) (UpsertResult, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		r.sl.Error("Failed to start transaction", "error", err)
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Проверка и замена идут под блокировкой заказа: конкурирующая запись или удаление данных
+	// того же заказа дождутся коммита и увидят результат
+	exists, err := lockOrder(ctx, tx, order.OrderUID)
+	if err != nil {
+		r.sl.Error("Failed to lock order", "order_uid", order.OrderUID, "error", err)
+		return 0, err
+	}
+	if !exists {
+		if err = r.insertOrder(ctx, tx, order, issues, EventOrderCreated); err != nil {
+			return 0, err
+		}
+		if err = tx.Commit(ctx); err != nil {
+			r.sl.Error("Failed to commit transaction", "error", err)
+			return 0, err
+		}
+		r.sl.Info("Order successfully saved", "order_uid", order.OrderUID)
+		return Inserted, nil
+	}
+
+	current, err := r.getOrder(ctx, tx, order.OrderUID)
+	if err != nil {
+		return 0, err
+	}
+	if current.Delivery.Name == models.ErasedValue {
+		eraseDelivery(&order.Delivery)
+	}
+	if sameOrder(*current, order) {
+		return Unchanged, nil
+	}
+
+	// Удаляем прежнюю версию со всеми связанными записями; товары удаляются каскадно
+	stmts := []string{
+		`CREATE TEMP TABLE replaced_orders ON COMMIT DROP AS
+		 SELECT delivery_id, payment_id FROM all_orders WHERE order_uid = $1`,
+		`DELETE FROM orders WHERE order_uid = $1`,
+		`DELETE FROM orders_archive WHERE order_uid = $1`,
+		`DELETE FROM deliveries WHERE id IN (SELECT delivery_id FROM replaced_orders)`,
+		`DELETE FROM payments WHERE id IN (SELECT payment_id FROM replaced_orders)`,
+		`DELETE FROM reconciliation_issues WHERE order_uid = $1`,
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(ctx, stmt, order.OrderUID); err != nil {
+			r.sl.Error("Failed to remove previous order version", "order_uid", order.OrderUID, "error", err)
+			return 0, err
+		}
+	}
+
+	if err = r.insertOrder(ctx, tx, order, issues, EventOrderUpdated); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		r.sl.Error("Failed to commit transaction", "error", err)
+		return 0, err
+	}
+
+	r.sl.Info("Order successfully replaced", "order_uid", order.OrderUID)
+	return Updated, nil
+}
+
+// eraseDelivery заменяет персональные данные доставки так же, как EraseCustomerData
+func eraseDelivery(d *models.Delivery) {
+	d.Name, d.Phone, d.Zip, d.Address, d.Email = models.ErasedValue, models.ErasedValue, models.ErasedValue, models.ErasedValue, models.ErasedValue
+}
+
+// sameOrder сравнивает заказы так, как они хранятся в бд: date_created — без часового пояса
+// с точностью до микросекунды; nil и пустой список товаров не различаются
+func sameOrder(a, b models.Order) bool {
+	if !storedTime(a.DateCreated).Equal(storedTime(b.DateCreated)) || len(a.Items) != len(b.Items) {
+		return false
+	}
+	a.DateCreated = b.DateCreated
+	if len(a.Items) == 0 {
+		a.Items, b.Items = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// storedTime — время в том виде, в котором его вернёт столбец TIMESTAMP
+func storedTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).Truncate(time.Microsecond)
+}
@@ -0,0 +1,139 @@
+// Package export выгружает заказы в CSV: одной строкой на заказ или на товар.
+package export
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/models"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Granularity — чему соответствует строка выгрузки
+type Granularity string
+
+const (
+	PerOrder Granularity = "order"
+	PerItem  Granularity = "item"
+)
+
+// ParseGranularity разбирает значение параметра per
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(strings.ToLower(s)); g {
+	case "":
+		return PerOrder, nil
+	case PerOrder, PerItem:
+		return g, nil
+	}
+	return "", fmt.Errorf("unknown export granularity %q", s)
+}
+
+// Source — откуда читать заказы; реализуется repository.Repo
+type Source interface {
+	StreamOrders(ctx context.Context, filter repository.OrderFilter, fn func(models.Order) error) error
+}
+
+// Options — параметры выгрузки
+type Options struct {
+	Per Granularity
+	// Excel — совместимость с Excel: BOM UTF-8, переводы строк CRLF и защита от формул
+	// в текстовых ячейках (значения, начинающиеся с = + - @, предваряются апострофом)
+	Excel bool
+}
+
+// utf8BOM — по нему Excel определяет кодировку файла
+const utf8BOM = "\uFEFF"
+
+var orderHeader = []string{
+	"order_uid", "date_created", "customer_id", "delivery_service", "track_number", "entry", "locale",
+	"city", "region", "transaction", "currency", "provider", "bank", "payment_dt",
+}
+
+var orderOnlyHeader = []string{"items", "goods_total", "delivery_cost", "custom_fee", "amount"}
+
+var itemHeader = []string{"chrt_id", "nm_id", "rid", "name", "brand", "size", "status", "price", "sale", "total_price"}
+
+// Write выгружает заказы из src, подходящие под filter, в w. Возвращает количество выгруженных заказов.
+// Заказы обрабатываются по одному и в память целиком не загружаются
+func Write(ctx context.Context, w io.Writer, src Source, filter repository.OrderFilter, opts Options) (int, error) {
+	if opts.Excel {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return 0, err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = opts.Excel
+
+	header := append([]string{}, orderHeader...)
+	if opts.Per == PerItem {
+		header = append(header, itemHeader...)
+	} else {
+		header = append(header, orderOnlyHeader...)
+	}
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+
+	text := func(s string) string { return s }
+	if opts.Excel {
+		text = escapeFormula
+	}
+
+	n := 0
+	err := src.StreamOrders(ctx, filter, func(order models.Order) error {
+		p := order.Payment
+		base := []string{
+			text(order.OrderUID), order.DateCreated.UTC().Format(time.RFC3339), text(order.CustomerID), text(order.DeliveryService),
+			text(order.TrackNumber), text(order.Entry), text(order.Locale), text(order.Delivery.City), text(order.Delivery.Region),
+			text(p.Transaction), text(p.Currency), text(p.Provider), text(p.Bank), time.Unix(p.PaymentDT, 0).UTC().Format(time.RFC3339),
+		}
+
+		if opts.Per == PerItem {
+			// Заказ без товаров тоже попадает в выгрузку — с пустыми колонками товара
+			if len(order.Items) == 0 {
+				if err := cw.Write(append(base, make([]string, len(itemHeader))...)); err != nil {
+					return err
+				}
+			}
+			for _, item := range order.Items {
+				row := append(append([]string{}, base...),
+					strconv.Itoa(item.ChrtID), strconv.Itoa(item.NmID), text(item.Rid), text(item.Name), text(item.Brand), text(item.Size),
+					strconv.Itoa(item.Status), p.Money(item.Price).Decimal(), strconv.Itoa(item.Sale), p.Money(item.TotalPrice).Decimal(),
+				)
+				if err := cw.Write(row); err != nil {
+					return err
+				}
+			}
+		} else {
+			row := append(base,
+				strconv.Itoa(len(order.Items)), p.Money(p.GoodsTotal).Decimal(), p.Money(p.DeliveryCost).Decimal(),
+				p.Money(p.CustomFee).Decimal(), p.Money(p.Amount).Decimal(),
+			)
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	cw.Flush()
+	return n, cw.Error()
+}
+
+// escapeFormula не даёт табличному редактору интерпретировать значение как формулу
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
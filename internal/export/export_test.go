@@ -0,0 +1,156 @@
+package export
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/models"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// orders — Source из заранее заданных заказов
+type orders []models.Order
+
+func (o orders) StreamOrders(_ context.Context, _ repository.OrderFilter, fn func(models.Order) error) error {
+	for _, order := range o {
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		DateCreated:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Delivery:        models.Delivery{City: "Kiryat Mozkin", Region: "Kraiot"},
+		Payment:         models.Payment{Currency: "USD", Amount: 1817, GoodsTotal: 317, DeliveryCost: 1500},
+		Items: []models.Item{{
+			ChrtID: 9934930, NmID: 2389212, Rid: "ab4219087a764ae0btest", Name: "Mascaras", Brand: "Vivienne Sabo",
+			Size: "0", Status: 202, Price: 453, Sale: 30, TotalPrice: 317,
+		}},
+	}
+}
+
+func write(t *testing.T, opts Options, src ...models.Order) string {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := Write(context.Background(), &buf, orders(src), repository.OrderFilter{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(src) {
+		t.Fatalf("Write = %d orders, want %d", n, len(src))
+	}
+	return buf.String()
+}
+
+func readRows(t *testing.T, s string) [][]string {
+	t.Helper()
+	rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(s, utf8BOM))).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v\n%s", err, s)
+	}
+	return rows
+}
+
+func column(t *testing.T, rows [][]string, name string) string {
+	t.Helper()
+	i := slices.Index(rows[0], name)
+	if i < 0 {
+		t.Fatalf("no column %q in %v", name, rows[0])
+	}
+	return rows[1][i]
+}
+
+func TestWriteQuotesSpecialCharacters(t *testing.T) {
+	order := testOrder()
+	order.Delivery.City = `Tel Aviv, "Center"`
+	order.Items[0].Name = "Mascara\nblack"
+
+	out := write(t, Options{Per: PerItem}, order)
+	if !strings.Contains(out, `"Tel Aviv, ""Center"""`) {
+		t.Fatalf("city is not quoted:\n%s", out)
+	}
+
+	rows := readRows(t, out)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want header and one item", len(rows))
+	}
+	if got := column(t, rows, "city"); got != order.Delivery.City {
+		t.Fatalf("city = %q, want %q", got, order.Delivery.City)
+	}
+	if got := column(t, rows, "name"); got != order.Items[0].Name {
+		t.Fatalf("name = %q, want %q", got, order.Items[0].Name)
+	}
+}
+
+func TestWriteExcel(t *testing.T) {
+	order := testOrder()
+	order.CustomerID = "=HYPERLINK(\"http://example.com\")"
+	order.Items[0].Name = "-5 sale"
+	order.Items[0].Brand = "@brand"
+
+	out := write(t, Options{Per: PerItem, Excel: true}, order)
+	if !strings.HasPrefix(out, utf8BOM) {
+		t.Fatal("no UTF-8 BOM")
+	}
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Fatal("rows are not terminated with CRLF")
+	}
+
+	rows := readRows(t, out)
+	for col, want := range map[string]string{
+		"customer_id": "'" + order.CustomerID,
+		"name":        "'-5 sale",
+		"brand":       "'@brand",
+		"order_uid":   order.OrderUID,
+		"price":       "453.00", // Числа не экранируются
+	} {
+		if got := column(t, rows, col); got != want {
+			t.Errorf("%s = %q, want %q", col, got, want)
+		}
+	}
+}
+
+func TestWriteWithoutExcelKeepsValues(t *testing.T) {
+	order := testOrder()
+	order.CustomerID = "=1+1"
+
+	out := write(t, Options{}, order)
+	if strings.HasPrefix(out, utf8BOM) || strings.Contains(out, "\r\n") {
+		t.Fatal("Excel formatting applied without Excel option")
+	}
+	rows := readRows(t, out)
+	if got := column(t, rows, "customer_id"); got != "=1+1" {
+		t.Fatalf("customer_id = %q, want it unchanged", got)
+	}
+	if got := column(t, rows, "amount"); got != "1817.00" {
+		t.Fatalf("amount = %q, want 1817.00", got)
+	}
+}
+
+func TestWritePerItemOrderWithoutItems(t *testing.T) {
+	order := testOrder()
+	order.Items = nil
+
+	rows := readRows(t, write(t, Options{Per: PerItem}, order))
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want header and one row", len(rows))
+	}
+	if len(rows[1]) != len(rows[0]) {
+		t.Fatalf("row has %d columns, header %d", len(rows[1]), len(rows[0]))
+	}
+	if got := column(t, rows, "chrt_id"); got != "" {
+		t.Fatalf("chrt_id = %q, want empty", got)
+	}
+}
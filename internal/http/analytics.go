@@ -0,0 +1,94 @@
+package http
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/service"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultStatsPeriod — период отчёта, если from не указан
+const defaultStatsPeriod = 30 * 24 * time.Hour
+
+// statRow — строка отчёта в ответе
+type statRow struct {
+	Key         string `json:"key"`
+	Orders      int64  `json:"orders"`
+	Revenue     string `json:"revenue"`
+	Currency    string `json:"currency"`
+	Unconverted int64  `json:"unconverted_orders"` // Заказы без курса валюты, не вошедшие в выручку
+}
+
+// handleOrderStats отдаёт отчёт по разрезу {dimension}.
+// Параметры: ?from= и ?to= (YYYY-MM-DD, включительно; по умолчанию последние 30 дней), ?format=json|csv
+func handleOrderStats(svc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dimension := r.PathValue("dimension")
+		if !repository.IsDimension(dimension) {
+			http.Error(w, "Unknown dimension", http.StatusNotFound)
+			return
+		}
+
+		to := time.Now().UTC().Truncate(24 * time.Hour)
+		from := to.Add(-defaultStatsPeriod)
+		var err error
+		if v := r.URL.Query().Get("from"); v != "" {
+			if from, err = time.Parse(time.DateOnly, v); err != nil {
+				http.Error(w, "Invalid from date", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := r.URL.Query().Get("to"); v != "" {
+			if to, err = time.Parse(time.DateOnly, v); err != nil {
+				http.Error(w, "Invalid to date", http.StatusBadRequest)
+				return
+			}
+		}
+		if to.Before(from) {
+			http.Error(w, "to is before from", http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			http.Error(w, "Invalid format", http.StatusBadRequest)
+			return
+		}
+
+		stats, err := svc.OrderStats(r.Context(), dimension, from, to)
+		if err != nil {
+			http.Error(w, "Failed to build report", http.StatusInternalServerError)
+			return
+		}
+
+		rows := make([]statRow, len(stats))
+		for i, s := range stats {
+			rows[i] = statRow{Key: s.Key, Orders: s.Orders, Revenue: s.Revenue.Decimal(), Currency: s.Revenue.Currency.Code, Unconverted: s.Unconverted}
+		}
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-by-%s-%s-%s.csv"`,
+				dimension, from.Format(time.DateOnly), to.Format(time.DateOnly)))
+			cw := csv.NewWriter(w)
+			_ = cw.Write([]string{dimension, "orders", "revenue", "currency", "unconverted_orders"})
+			for _, row := range rows {
+				_ = cw.Write([]string{row.Key, strconv.FormatInt(row.Orders, 10), row.Revenue, row.Currency, strconv.FormatInt(row.Unconverted, 10)})
+			}
+			cw.Flush()
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Dimension string    `json:"dimension"`
+			From      string    `json:"from"`
+			To        string    `json:"to"`
+			Rows      []statRow `json:"rows"`
+		}{dimension, from.Format(time.DateOnly), to.Format(time.DateOnly), rows})
+	}
+}
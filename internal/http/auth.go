@@ -0,0 +1,183 @@
+package http
+
+import (
+	"WBTechL0/internal/config"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role — роль пользователя; роли упорядочены по возрастанию прав
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleSupport
+	RoleAdmin
+)
+
+// ParseRole переводит название роли из конфига или токена в Role
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "viewer":
+		return RoleViewer, nil
+	case "support":
+		return RoleSupport, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", s)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleSupport:
+		return "support"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// Allows проверяет, что роль не ниже требуемой
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+// CanSeePII — доступ к персональным данным покупателя есть у support и admin
+func (r Role) CanSeePII() bool {
+	return r.Allows(RoleSupport)
+}
+
+// Principal — аутентифицированный субъект запроса
+type Principal struct {
+	Subject string
+	Role    Role
+	Method  string // api-key, jwt или anonymous
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext возвращает субъект, сохранённый middleware аутентификации
+func PrincipalFromContext(ctx context.Context) Principal {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	if !ok {
+		return Principal{Role: RoleNone}
+	}
+	return p
+}
+
+var errUnauthenticated = errors.New("missing credentials")
+
+// apiKey — ключ из конфига с уже декодированным хешем
+type apiKey struct {
+	name string
+	hash []byte
+	role Role
+}
+
+// Authenticator проверяет API-ключи и JWT
+type Authenticator struct {
+	enabled bool
+	apiKeys []apiKey
+	jwt     *jwtVerifier
+}
+
+// NewAuthenticator создаёт Authenticator по настройкам из конфига
+func NewAuthenticator(cfgAuth config.Auth) (*Authenticator, error) {
+	a := &Authenticator{enabled: cfgAuth.Enabled}
+	if !cfgAuth.Enabled {
+		return a, nil
+	}
+
+	for _, k := range cfgAuth.APIKeys {
+		if k.SHA256 == "" {
+			continue
+		}
+		hash, err := hex.DecodeString(k.SHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: sha256 must be a hex-encoded SHA-256 digest", k.Name)
+		}
+		role, err := ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{name: k.Name, hash: hash, role: role})
+	}
+
+	if cfgAuth.JWKSFile != "" {
+		v, err := newJWTVerifier(cfgAuth)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = v
+	}
+
+	return a, nil
+}
+
+// Authenticate определяет субъект по заголовкам запроса.
+// Без аутентификации любой запрос анонимный с ролью viewer: персональные данные и админские маршруты ему недоступны
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if !a.enabled {
+		return Principal{Subject: "anonymous", Role: RoleViewer, Method: "anonymous"}, nil
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found {
+		return Principal{}, errUnauthenticated
+	}
+	switch strings.ToLower(scheme) {
+	case "apikey":
+		return a.authenticateAPIKey(strings.TrimSpace(token))
+	case "bearer":
+		if a.jwt == nil {
+			return Principal{}, errors.New("bearer tokens are not accepted")
+		}
+		return a.jwt.verify(strings.TrimSpace(token))
+	}
+	return Principal{}, fmt.Errorf("unsupported authorization scheme %q", scheme)
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+			return Principal{Subject: k.name, Role: k.role, Method: "api-key"}, nil
+		}
+	}
+	return Principal{}, errors.New("invalid api key")
+}
+
+// requireRole — middleware, пропускающий запрос только с ролью не ниже required
+func (s *Server) requireRole(required Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := s.auth.Authenticate(r)
+		if err != nil {
+			s.svc.Sl.Warn("Authentication failed", "path", r.URL.Path, "remote_addr", r.RemoteAddr, "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !principal.Role.Allows(required) {
+			s.svc.Sl.Warn("Access denied", "path", r.URL.Path, "subject", principal.Subject, "role", principal.Role.String())
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+		next(w, r.WithContext(ctx))
+	}
+}
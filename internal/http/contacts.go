@@ -0,0 +1,49 @@
+package http
+
+import (
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/service"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// contactQuery выбирает из параметров запроса ровно один контакт: ?email= или ?phone=
+func contactQuery(q url.Values) (kind, value string, ok bool) {
+	email, phone := q.Get("email"), q.Get("phone")
+	switch {
+	case email != "" && phone == "":
+		return "email", email, true
+	case phone != "" && email == "":
+		return "phone", phone, true
+	}
+	return "", "", false
+}
+
+// handleOrdersByContact отдаёт заказы получателя, найденные по email или телефону (?email= или ?phone=)
+func handleOrdersByContact(svc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind, value, ok := contactQuery(r.URL.Query())
+		if !ok {
+			http.Error(w, "Exactly one of email or phone is required", http.StatusBadRequest)
+			return
+		}
+
+		orders, err := svc.FindOrdersByContact(r.Context(), kind, value)
+		if err != nil {
+			http.Error(w, "Failed to find orders", http.StatusInternalServerError)
+			return
+		}
+
+		// Сам контакт в лог не пишется: это персональные данные
+		svc.Sl.Info("Orders looked up by contact", "kind", kind, "orders", len(orders), "by", PrincipalFromContext(r.Context()).Subject)
+
+		w.Header().Set("Content-Type", "application/json")
+		if orders == nil {
+			orders = []models.Order{}
+		}
+		if err = json.NewEncoder(w).Encode(orders); err != nil {
+			svc.Sl.Error("Failed to write orders", "error", err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestContactQuery(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantKind  string
+		wantValue string
+		wantOK    bool
+	}{
+		{"email=a%40b.io", "email", "a@b.io", true},
+		{"phone=%2B7+900+123", "phone", "+7 900 123", true},
+		{"email=a%40b.io&phone=123", "", "", false},
+		{"", "", "", false},
+		{"email=", "", "", false},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kind, value, ok := contactQuery(q)
+		if kind != tt.wantKind || value != tt.wantValue || ok != tt.wantOK {
+			t.Errorf("contactQuery(%q) = %q, %q, %v; want %q, %q, %v", tt.query, kind, value, ok, tt.wantKind, tt.wantValue, tt.wantOK)
+		}
+	}
+}
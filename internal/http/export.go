@@ -0,0 +1,59 @@
+package http
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/export"
+	"WBTechL0/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handleExportOrders отдаёт заказы в CSV потоком.
+// Параметры: ?from= и ?to= (YYYY-MM-DD, включительно), ?customer=, ?service=,
+// ?per=order|item (по умолчанию order), ?excel=true — файл для открытия в Excel
+func handleExportOrders(svc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var filter repository.OrderFilter
+		var err error
+		if v := q.Get("from"); v != "" {
+			if filter.From, err = time.Parse(time.DateOnly, v); err != nil {
+				http.Error(w, "Invalid from date", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := q.Get("to"); v != "" {
+			to, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				http.Error(w, "Invalid to date", http.StatusBadRequest)
+				return
+			}
+			filter.To = to.AddDate(0, 0, 1)
+		}
+		filter.CustomerID = q.Get("customer")
+		filter.DeliveryService = q.Get("service")
+
+		opts := export.Options{}
+		if opts.Per, err = export.ParseGranularity(q.Get("per")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if v := q.Get("excel"); v != "" {
+			if opts.Excel, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "Invalid excel flag", http.StatusBadRequest)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-%s.csv"`, time.Now().UTC().Format("20060102-150405")))
+
+		// Часть файла могла уже уйти клиенту: обрываем соединение, чтобы неполная выгрузка не выглядела целой
+		if _, err = svc.ExportOrders(r.Context(), w, filter, opts); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"WBTechL0/internal/config"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+)
+
+// jwk — ключ из JWKS (RFC 7517); поддерживаются RSA и EC
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwtVerifier проверяет подпись и claims Bearer-токенов по локальному JWKS
+type jwtVerifier struct {
+	keys      map[string]crypto.PublicKey
+	parser    *jwt.Parser
+	roleClaim string
+}
+
+func newJWTVerifier(cfgAuth config.Auth) (*jwtVerifier, error) {
+	keys, err := loadJWKS(cfgAuth.JWKSFile)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfgAuth.Leeway),
+	}
+	if cfgAuth.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfgAuth.Issuer))
+	}
+	if cfgAuth.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfgAuth.Audience))
+	}
+
+	return &jwtVerifier{keys: keys, parser: jwt.NewParser(opts...), roleClaim: cfgAuth.RoleClaim}, nil
+}
+
+// loadJWKS читает JWKS-файл и декодирует публичные ключи по kid
+func loadJWKS(path string) (map[string]crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks file: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks file: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks file contains no signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64url value: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// verify проверяет токен и извлекает из него субъект и роль
+func (v *jwtVerifier) verify(raw string) (Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := v.keys[kid]; ok {
+			return key, nil
+		}
+		// Токен без kid допустим, только если в JWKS ровно один ключ
+		if kid == "" && len(v.keys) == 1 {
+			for _, key := range v.keys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("invalid token: %w", err)
+	}
+
+	subject, _ := claims.GetSubject()
+	role := v.highestRole(claims[v.roleClaim])
+	if role == RoleNone {
+		return Principal{}, errors.New("token has no known role")
+	}
+	return Principal{Subject: subject, Role: role, Method: "jwt"}, nil
+}
+
+// highestRole выбирает максимальную роль из claim'а (строка или массив строк)
+func (v *jwtVerifier) highestRole(claim interface{}) Role {
+	var names []string
+	switch c := claim.(type) {
+	case string:
+		names = []string{c}
+	case []interface{}:
+		for _, n := range c {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+
+	best := RoleNone
+	for _, name := range names {
+		if role, err := ParseRole(name); err == nil && role > best {
+			best = role
+		}
+	}
+	return best
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"WBTechL0/internal/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testJWKS записывает JWKS с одним ключом P-256 и возвращает путь к файлу и закрытый ключ
+func testJWKS(t *testing.T, kid string) (string, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	set := map[string]any{"keys": []jwk{{
+		Kty: "EC", Kid: kid, Use: "sig", Alg: "ES256", Crv: "P-256",
+		X: b64(key.X.FillBytes(make([]byte, 32))), Y: b64(key.Y.FillBytes(make([]byte, 32))),
+	}}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, key
+}
+
+func TestJWTVerify(t *testing.T) {
+	path, key := testJWKS(t, "k1")
+	v, err := newJWTVerifier(config.Auth{
+		JWKSFile: path, Issuer: "https://issuer.test", Audience: "orders", RoleClaim: "role", Leeway: 30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	claims := func(mod func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub": "alice", "iss": "https://issuer.test", "aud": "orders", "role": "support",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		if mod != nil {
+			mod(c)
+		}
+		return c
+	}
+	es256 := func(c jwt.MapClaims, kid string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	foreign, _ := jwt.NewWithClaims(jwt.SigningMethodES256, claims(nil)).SignedString(otherKey)
+	// Подмена алгоритма: HMAC с публичным ключом в качестве секрета
+	hs256, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil)).SignedString(key.X.Bytes())
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name     string
+		token    string
+		wantRole Role
+		wantErr  bool
+	}{
+		{"valid", es256(claims(nil), "k1"), RoleSupport, false},
+		{"no kid with single key", es256(claims(nil), ""), RoleSupport, false},
+		{"highest of several roles", es256(claims(func(c jwt.MapClaims) { c["role"] = []any{"viewer", "admin", "unknown"} }), "k1"), RoleAdmin, false},
+		{"expired within leeway", es256(claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-10 * time.Second).Unix() }), "k1"), RoleSupport, false},
+		{"expired", es256(claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }), "k1"), RoleNone, true},
+		{"no exp", es256(claims(func(c jwt.MapClaims) { delete(c, "exp") }), "k1"), RoleNone, true},
+		{"not yet valid", es256(claims(func(c jwt.MapClaims) { c["nbf"] = now.Add(time.Hour).Unix() }), "k1"), RoleNone, true},
+		{"wrong audience", es256(claims(func(c jwt.MapClaims) { c["aud"] = "billing" }), "k1"), RoleNone, true},
+		{"audience in list", es256(claims(func(c jwt.MapClaims) { c["aud"] = []any{"billing", "orders"} }), "k1"), RoleSupport, false},
+		{"wrong issuer", es256(claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.test" }), "k1"), RoleNone, true},
+		{"unknown kid", es256(claims(nil), "k2"), RoleNone, true},
+		{"no role", es256(claims(func(c jwt.MapClaims) { delete(c, "role") }), "k1"), RoleNone, true},
+		{"unknown role", es256(claims(func(c jwt.MapClaims) { c["role"] = "root" }), "k1"), RoleNone, true},
+		{"signed by another key", foreign, RoleNone, true},
+		{"hs256", hs256, RoleNone, true},
+		{"alg none", none, RoleNone, true},
+		{"garbage", "not.a.token", RoleNone, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if p.Role != tt.wantRole {
+				t.Fatalf("role = %s, want %s", p.Role, tt.wantRole)
+			}
+			if !tt.wantErr && (p.Subject != "alice" || p.Method != "jwt") {
+				t.Fatalf("principal = %+v", p)
+			}
+		})
+	}
+}
+
+func TestAuthenticateDisabledIsAnonymousViewer(t *testing.T) {
+	a, err := NewAuthenticator(config.Auth{Enabled: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Authenticate(httptest.NewRequest("GET", "/order/x", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Role != RoleViewer || p.Role.CanSeePII() || p.Role.Allows(RoleAdmin) {
+		t.Fatalf("anonymous principal = %+v, want viewer without PII or admin access", p)
+	}
+}
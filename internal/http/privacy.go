@@ -0,0 +1,61 @@
+package http
+
+import (
+	"WBTechL0/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// handleExportCustomer отдаёт все данные покупателя одним JSON-файлом
+func handleExportCustomer(svc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		customerID := r.PathValue("id")
+
+		export, err := svc.ExportCustomerData(r.Context(), customerID)
+		if errors.Is(err, service.ErrCustomerNotFound) {
+			http.Error(w, "Customer not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to export customer data", http.StatusInternalServerError)
+			return
+		}
+
+		svc.Sl.Info("Customer export requested", "customer_id", customerID, "by", PrincipalFromContext(r.Context()).Subject)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "customer-"+customerID+".json"))
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(export); err != nil {
+			svc.Sl.Error("Failed to write customer export", "error", err)
+		}
+	}
+}
+
+// handleEraseCustomer обезличивает персональные данные покупателя
+func handleEraseCustomer(svc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		customerID := r.PathValue("id")
+
+		uids, err := svc.EraseCustomerData(r.Context(), customerID)
+		if errors.Is(err, service.ErrCustomerNotFound) {
+			http.Error(w, "Customer not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to erase customer data", http.StatusInternalServerError)
+			return
+		}
+
+		svc.Sl.Info("Customer erasure requested", "customer_id", customerID, "by", PrincipalFromContext(r.Context()).Subject)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			CustomerID string   `json:"customer_id"`
+			Orders     []string `json:"erased_orders"`
+		}{customerID, uids})
+	}
+}
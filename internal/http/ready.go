@@ -0,0 +1,29 @@
+package http
+
+import (
+	"WBTechL0/internal/cache"
+	"WBTechL0/internal/service"
+	"encoding/json"
+	"net/http"
+)
+
+// handleReady — проверка готовности. Сервер готов сразу после старта: пока кэш прогревается,
+// промахи читаются из базы, поэтому ответ всегда 200, а ход прогрева отдаётся в теле
+func handleReady(svc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		progress := svc.Cache.Warmup()
+
+		resp := struct {
+			Status string               `json:"status"`
+			Cache  cache.WarmupProgress `json:"cache"`
+			Orders int                  `json:"cached_orders"`
+		}{Status: "ready", Cache: progress, Orders: svc.Cache.Len()}
+		if progress.State == cache.WarmupPending || progress.State == cache.WarmupRunning {
+			resp.Status = "warming"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
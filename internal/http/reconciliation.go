@@ -0,0 +1,41 @@
+package http
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/service"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+const defaultReportLimit = 100
+
+// handleReconciliationReport отдаёт список заказов с несходящимися суммами; ?limit= ограничивает количество
+func handleReconciliationReport(svc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultReportLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		orders, err := svc.ReconciliationReport(r.Context(), limit)
+		if err != nil {
+			http.Error(w, "Failed to build reconciliation report", http.StatusInternalServerError)
+			return
+		}
+		if orders == nil {
+			orders = []repository.FlaggedOrder{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Mode   string                    `json:"mode"`
+			Orders []repository.FlaggedOrder `json:"orders"`
+		}{string(svc.ReconcileMode), orders})
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"WBTechL0/internal/redact"
+	"WBTechL0/internal/search"
+	"WBTechL0/internal/service"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// searchPage — данные шаблона страницы поиска
+type searchPage struct {
+	Query   string
+	Results []service.SearchResult
+}
+
+// handleSearch — страница полнотекстового поиска заказов (?q=, ?limit=, по умолчанию 20)
+func handleSearch(svc *service.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+
+		query := r.URL.Query().Get("q")
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		// Персональные данные видят и ищут только support и admin
+		canSeePII := PrincipalFromContext(r.Context()).Role.CanSeePII()
+
+		page := searchPage{Query: query}
+		if query != "" {
+			results, err := svc.SearchOrders(r.Context(), query, canSeePII, limit)
+			if err != nil {
+				http.Error(w, "Search failed", http.StatusInternalServerError)
+				return
+			}
+			if !canSeePII {
+				for i := range results {
+					results[i].Order = *redact.Mask(&results[i].Order)
+				}
+			}
+			page.Results = results
+		}
+
+		terms := search.Terms(query)
+		tmpl, err := template.New("search.html").Funcs(template.FuncMap{
+			"highlight": func(text string) template.HTML {
+				return search.Highlight(text, terms)
+			},
+		}).ParseFiles("templates/search.html")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err = tmpl.Execute(w, page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package http
 
 import (
 	"WBTechL0/internal/config"
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/redact"
 	"WBTechL0/internal/service"
+	"context"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -10,25 +13,47 @@ import (
 
 // Server Структура Сервера
 type Server struct {
-	svc *service.OrderService
-	cfg config.HttpServer
+	svc  *service.OrderService
+	cfg  config.HttpServer
+	auth *Authenticator
 }
 
 // New - Конструктор для создания нового httpServer
-func New(svc *service.OrderService, cfgHttp config.HttpServer) *Server {
-	return &Server{svc: svc, cfg: cfgHttp}
+func New(svc *service.OrderService, cfgHttp config.HttpServer, auth *Authenticator) *Server {
+	return &Server{svc: svc, cfg: cfgHttp, auth: auth}
 }
 
 // Start - Метод для запуска HTTP сервера
 func (s *Server) Start() {
 	m := http.NewServeMux()
 
-	m.HandleFunc("GET /id", handleMain)
-	m.HandleFunc("GET /id/{uid}", handleGetOrder(s.svc))
-	m.HandleFunc("POST /id", handlePostOrder)
+	// Готовность и ход прогрева кэша; без аутентификации, персональных данных в ответе нет
+	m.HandleFunc("GET /ready", handleReady(s.svc))
+
+	m.HandleFunc("GET /id", s.requireRole(RoleViewer, handleMain))
+	m.HandleFunc("GET /id/{uid}", s.requireRole(RoleViewer, handleGetOrder(s.svc)))
+	m.HandleFunc("POST /id", s.requireRole(RoleViewer, handlePostOrder))
+	m.HandleFunc("GET /search", s.requireRole(RoleViewer, handleSearch(s.svc)))
+
+	// Поиск заказов по email или телефону получателя для поддержки
+	m.HandleFunc("GET /support/orders", s.requireRole(RoleSupport, handleOrdersByContact(s.svc)))
+
+	// Запросы субъектов персональных данных
+	m.HandleFunc("GET /admin/customers/{id}/export", s.requireRole(RoleAdmin, handleExportCustomer(s.svc)))
+	m.HandleFunc("POST /admin/customers/{id}/erase", s.requireRole(RoleAdmin, handleEraseCustomer(s.svc)))
+
+	// Отчёт о заказах с несходящимися суммами
+	m.HandleFunc("GET /admin/reconciliation", s.requireRole(RoleAdmin, handleReconciliationReport(s.svc)))
+
+	// Отчёты: количество заказов и выручка по дням, службам доставки, регионам, провайдерам, банкам и брендам
+	m.HandleFunc("GET /admin/analytics/{dimension}", s.requireRole(RoleAdmin, handleOrderStats(s.svc)))
+
+	// Выгрузка заказов в CSV
+	m.HandleFunc("GET /admin/export/orders", s.requireRole(RoleAdmin, handleExportOrders(s.svc)))
+
 	port := fmt.Sprintf(":%v", s.cfg.Port)
 	if err := http.ListenAndServe(port, m); err != nil {
-		s.svc.Sl.Error("Could not start server: %v", err)
+		s.svc.Sl.Error("Could not start server", "error", err)
 	}
 	// Запуск сервера
 	s.svc.Sl.Info("Starting HTTP server")
@@ -51,8 +76,13 @@ func handleGetOrder(svc *service.OrderService) http.HandlerFunc {
 			return
 		}
 
+		// Персональные данные видят только support и admin
+		if !PrincipalFromContext(r.Context()).Role.CanSeePII() {
+			order = redact.Mask(order)
+		}
+
 		// Парсинг шаблона
-		tmpl, err := template.ParseFiles("templates/order.html")
+		tmpl, err := template.New("order.html").Funcs(moneyFuncs(r.Context(), svc, order)).ParseFiles("templates/order.html")
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -66,6 +96,27 @@ func handleGetOrder(svc *service.OrderService) http.HandlerFunc {
 	}
 }
 
+// moneyFuncs — функции шаблона для вывода сумм заказа в его валюте по правилам его локали
+// и в валюте отчётности
+func moneyFuncs(ctx context.Context, svc *service.OrderService, order *models.Order) template.FuncMap {
+	return template.FuncMap{
+		"money": func(amount int) string {
+			return order.Payment.Money(amount).Format(order.Locale)
+		},
+		"inBase": func(amount int) string {
+			m := order.Payment.Money(amount)
+			if m.Currency.Code == svc.BaseCurrency.Code {
+				return ""
+			}
+			converted, err := svc.ConvertToBase(ctx, m, order.DateCreated)
+			if err != nil {
+				return ""
+			}
+			return converted.Format(order.Locale)
+		},
+	}
+}
+
 func handlePostOrder(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
 		err := r.ParseForm()
@@ -0,0 +1,246 @@
+// Package importer — массовая загрузка заказов из файла JSON Lines (в том числе сжатого gzip).
+package importer
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/service"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Options — параметры загрузки
+type Options struct {
+	Path      string // Файл JSON Lines: один заказ на строку; gzip определяется по содержимому
+	BatchSize int    // Сколько строк сохранять одной транзакцией
+	// Checkpoint — файл с номером последней обработанной строки; загрузка продолжается с неё
+	Checkpoint string
+	// Rejects — файл JSON Lines с отклонёнными строками и причинами; дописывается при продолжении
+	Rejects string
+	Restart bool // Начать с первой строки, игнорируя Checkpoint
+}
+
+// Rejected — отклонённая строка
+type Rejected struct {
+	Line     int    `json:"line"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// Summary — итог загрузки
+type Summary struct {
+	ResumedFrom int `json:"resumed_from"` // Строки до этой включительно были обработаны прошлыми запусками
+	LastLine    int `json:"last_line"`
+	Imported    int `json:"imported"`
+	Skipped     int `json:"skipped"` // Заказ уже есть в бд
+	Rejected    int `json:"rejected"`
+}
+
+// Run загружает заказы из opts.Path. Каждая строка проверяется сервисом (валидация и сверка сумм),
+// подходящие заказы сохраняются пачками. После каждой пачки отклонённые строки дописываются в opts.Rejects,
+// а номер строки — в opts.Checkpoint. При отмене ctx текущая пачка сохраняется, и Run возвращает ctx.Err()
+func Run(ctx context.Context, srv *service.OrderService, sl *slog.Logger, opts Options) (Summary, error) {
+	var sum Summary
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	if !opts.Restart {
+		n, err := readCheckpoint(opts.Checkpoint)
+		if err != nil {
+			return sum, err
+		}
+		sum.ResumedFrom = n
+	}
+
+	in, err := os.Open(opts.Path)
+	if err != nil {
+		return sum, err
+	}
+	defer in.Close()
+	r, err := newLineReader(in)
+	if err != nil {
+		return sum, err
+	}
+
+	// При старте с начала прошлый отчёт об отклонённых строках больше не актуален
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if sum.ResumedFrom == 0 {
+		flags |= os.O_TRUNC
+	}
+	rejectsFile, err := os.OpenFile(opts.Rejects, flags, 0o644)
+	if err != nil {
+		return sum, err
+	}
+	defer rejectsFile.Close()
+
+	b := batch{srv: srv, rejects: rejectsFile, checkpoint: opts.Checkpoint, sum: &sum}
+	line := 0
+	for {
+		if ctx.Err() != nil {
+			// Сохраняем уже прочитанное, чтобы продолжение не начинало пачку заново
+			if err = b.flush(context.WithoutCancel(ctx), line); err != nil {
+				return sum, err
+			}
+			return sum, ctx.Err()
+		}
+
+		data, readErr := r.ReadBytes('\n')
+		if len(data) > 0 {
+			line++
+			if line > sum.ResumedFrom {
+				b.add(line, data)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return sum, fmt.Errorf("line %d: %w", line+1, readErr)
+		}
+
+		if b.size() >= opts.BatchSize {
+			if err = b.flush(ctx, line); err != nil {
+				return sum, err
+			}
+			sl.Info("Import progress", "line", line, "imported", sum.Imported, "skipped", sum.Skipped, "rejected", sum.Rejected)
+		}
+	}
+
+	// Файл короче контрольной точки — его подменили или обрезали; продолжать по нему нельзя,
+	// а запись контрольной точки откатила бы её назад
+	if line < sum.ResumedFrom {
+		return sum, fmt.Errorf("%s has %d lines, but checkpoint %s is at line %d; use -restart to import it from the beginning",
+			opts.Path, line, opts.Checkpoint, sum.ResumedFrom)
+	}
+
+	if err = b.flush(ctx, line); err != nil {
+		return sum, err
+	}
+	return sum, nil
+}
+
+// batch — строки, прочитанные после последнего сохранения
+type batch struct {
+	srv        *service.OrderService
+	rejects    io.Writer
+	checkpoint string
+	sum        *Summary
+
+	lines    int
+	orders   []models.Order
+	orderAt  []int // Номера строк заказов
+	rejected []Rejected
+}
+
+func (b *batch) size() int { return b.lines }
+
+// add разбирает и проверяет строку; неподходящая сразу попадает в отклонённые
+func (b *batch) add(line int, data []byte) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return
+	}
+	b.lines++
+
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		b.rejected = append(b.rejected, Rejected{Line: line, Reason: "invalid json: " + err.Error()})
+		return
+	}
+	if _, err := b.srv.CheckOrder(order); err != nil {
+		b.rejected = append(b.rejected, Rejected{Line: line, OrderUID: order.OrderUID, Reason: err.Error()})
+		return
+	}
+	b.orders = append(b.orders, order)
+	b.orderAt = append(b.orderAt, line)
+}
+
+// flush сохраняет заказы, дописывает отклонённые строки и сдвигает контрольную точку на line
+func (b *batch) flush(ctx context.Context, line int) error {
+	if len(b.orders) > 0 {
+		errs, err := b.srv.SaveOrders(ctx, b.orders)
+		if err != nil {
+			return fmt.Errorf("failed to save batch ending at line %d: %w", line, err)
+		}
+		for i, err := range errs {
+			switch {
+			case err == nil:
+				b.sum.Imported++
+			case errors.Is(err, repository.ErrOrderExists):
+				b.sum.Skipped++
+			default:
+				b.rejected = append(b.rejected, Rejected{Line: b.orderAt[i], OrderUID: b.orders[i].OrderUID, Reason: err.Error()})
+			}
+		}
+	}
+
+	enc := json.NewEncoder(b.rejects)
+	for _, rej := range b.rejected {
+		if err := enc.Encode(rej); err != nil {
+			return err
+		}
+	}
+	if f, ok := b.rejects.(*os.File); ok {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	b.sum.Rejected += len(b.rejected)
+
+	if err := writeCheckpoint(b.checkpoint, line); err != nil {
+		return err
+	}
+	b.sum.LastLine = line
+
+	b.lines, b.orders, b.orderAt, b.rejected = 0, b.orders[:0], b.orderAt[:0], b.rejected[:0]
+	return nil
+}
+
+// newLineReader читает файл как есть или распаковывает gzip
+func newLineReader(f io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return bufio.NewReader(zr), nil
+	}
+	return br, nil
+}
+
+func readCheckpoint(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint file %s: %w", path, err)
+	}
+	return n, nil
+}
+
+// writeCheckpoint записывает номер строки атомарно: через временный файл и переименование
+func writeCheckpoint(path string, line int) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(line)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
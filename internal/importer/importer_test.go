@@ -0,0 +1,173 @@
+package importer
+
+import (
+	"WBTechL0/internal/money"
+	"WBTechL0/internal/reconcile"
+	"WBTechL0/internal/service"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Строки ниже отклоняются проверкой сервиса и до бд не доходят, поэтому репозиторий не нужен
+var testLines = []string{
+	`{"order_uid": "broken"`,
+	`{"order_uid": "no-currency"}`,
+	``,
+	`not json`,
+	`{"order_uid": "bad-currency", "payment": {"currency": "XXX"}}`,
+}
+
+func testService() *service.OrderService {
+	sl := slog.New(slog.NewTextHandler(io.Discard, nil))
+	base, _ := money.LookupCurrency("RUB")
+	return service.New(nil, nil, sl, base, reconcile.ModeFlag)
+}
+
+// testOptions записывает строки во входной файл во временном каталоге
+func testOptions(t *testing.T, lines []string) Options {
+	t.Helper()
+	dir := t.TempDir()
+	opts := Options{
+		Path:       filepath.Join(dir, "orders.jsonl"),
+		BatchSize:  2,
+		Checkpoint: filepath.Join(dir, "orders.checkpoint"),
+		Rejects:    filepath.Join(dir, "orders.rejects.jsonl"),
+	}
+	if err := os.WriteFile(opts.Path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func readRejects(t *testing.T, path string) []Rejected {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rejected []Rejected
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var r Rejected
+		if err = dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		rejected = append(rejected, r)
+	}
+	return rejected
+}
+
+func rejectedLines(rejected []Rejected) []int {
+	lines := make([]int, len(rejected))
+	for i, r := range rejected {
+		lines[i] = r.Line
+	}
+	return lines
+}
+
+func TestRunRejects(t *testing.T) {
+	opts := testOptions(t, testLines)
+	sum, err := Run(context.Background(), testService(), slog.Default(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Rejected != 4 || sum.Imported != 0 || sum.LastLine != len(testLines) {
+		t.Fatalf("Run = %+v, want 4 rejected and last line %d", sum, len(testLines))
+	}
+
+	rejected := readRejects(t, opts.Rejects)
+	if got, want := rejectedLines(rejected), []int{1, 2, 4, 5}; !slices.Equal(got, want) {
+		t.Fatalf("rejected lines = %v, want %v", got, want)
+	}
+	if rejected[1].OrderUID != "no-currency" || !strings.Contains(rejected[1].Reason, service.ErrInvalidOrder.Error()) {
+		t.Fatalf("rejected[1] = %+v, want invalid order no-currency", rejected[1])
+	}
+	if n, err := readCheckpoint(opts.Checkpoint); err != nil || n != len(testLines) {
+		t.Fatalf("checkpoint = %d, %v; want %d", n, err, len(testLines))
+	}
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	opts := testOptions(t, testLines)
+	if err := writeCheckpoint(opts.Checkpoint, 2); err != nil {
+		t.Fatal(err)
+	}
+	// Отчёт прошлого запуска дописывается, а не перезаписывается
+	prev := `{"line":1,"reason":"previous run"}` + "\n"
+	if err := os.WriteFile(opts.Rejects, []byte(prev), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := Run(context.Background(), testService(), slog.Default(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.ResumedFrom != 2 || sum.Rejected != 2 || sum.LastLine != len(testLines) {
+		t.Fatalf("Run = %+v, want resumed from 2 with 2 rejected", sum)
+	}
+	rejected := readRejects(t, opts.Rejects)
+	if got, want := rejectedLines(rejected), []int{1, 4, 5}; !slices.Equal(got, want) || rejected[0].Reason != "previous run" {
+		t.Fatalf("rejects = %+v, want previous report followed by lines 4 and 5", rejected)
+	}
+
+	// С начала: контрольная точка игнорируется, отчёт пишется заново
+	opts.Restart = true
+	sum, err = Run(context.Background(), testService(), slog.Default(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.ResumedFrom != 0 || sum.Rejected != 4 {
+		t.Fatalf("Run with restart = %+v, want 4 rejected from the beginning", sum)
+	}
+	if got, want := rejectedLines(readRejects(t, opts.Rejects)), []int{1, 2, 4, 5}; !slices.Equal(got, want) {
+		t.Fatalf("rejected lines after restart = %v, want %v", got, want)
+	}
+}
+
+func TestRunRejectsTruncatedFile(t *testing.T) {
+	opts := testOptions(t, testLines)
+	if err := writeCheckpoint(opts.Checkpoint, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Run(context.Background(), testService(), slog.Default(), opts); err == nil {
+		t.Fatal("Run over a file shorter than the checkpoint succeeded")
+	}
+	// Контрольная точка не откатывается
+	if n, err := readCheckpoint(opts.Checkpoint); err != nil || n != 10 {
+		t.Fatalf("checkpoint = %d, %v; want 10", n, err)
+	}
+}
+
+func TestRunGzip(t *testing.T) {
+	opts := testOptions(t, nil)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, strings.Join(testLines, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(opts.Path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := Run(context.Background(), testService(), slog.Default(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Последняя строка без перевода строки тоже читается
+	if sum.Rejected != 4 || sum.LastLine != len(testLines) {
+		t.Fatalf("Run = %+v, want 4 rejected and last line %d", sum, len(testLines))
+	}
+}
@@ -0,0 +1,242 @@
+// Package keyring реализует конвертное шифрование персональных данных.
+//
+// Для каждой записи генерируется собственный ключ данных (DEK), которым по AES-256-GCM
+// шифруются поля. DEK хранится рядом с записью в зашифрованном ("обёрнутом") виде
+// ключом шифрования ключей (KEK) из файла ключей. Ротация KEK сводится к перешифровке DEK.
+//
+// Формат файла ключей:
+//
+//	{
+//	  "primary": "2026-10",
+//	  "keys": [{"id": "2026-10", "key": "<base64, 32 байта>"}, {"id": "2025-01", "key": "..."}],
+//	  "blindIndexKey": "<base64, 32 байта>"
+//	}
+//
+// primary — ключ, которым оборачиваются новые DEK; остальные ключи нужны только для чтения
+// старых записей. blindIndexKey не ротируется: от него зависят слепые индексы.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const keySize = 32
+
+// ErrUnknownKey — запись зашифрована ключом, которого нет в файле ключей
+var ErrUnknownKey = errors.New("unknown key id")
+
+// Keyring — набор KEK и ключ слепого индекса
+type Keyring struct {
+	primary  string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+type keyringFile struct {
+	Primary string `json:"primary"`
+	Keys    []struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	} `json:"keys"`
+	BlindIndexKey string `json:"blindIndexKey"`
+}
+
+// Load читает файл ключей
+func Load(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	var f keyringFile
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+
+	kr := &Keyring{primary: f.Primary, keys: make(map[string]cipher.AEAD, len(f.Keys))}
+	for _, k := range f.Keys {
+		raw, err := decodeKey(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("keyring key %q: %w", k.ID, err)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		kr.keys[k.ID] = aead
+	}
+	if _, ok := kr.keys[kr.primary]; !ok {
+		return nil, fmt.Errorf("keyring primary key %q is not defined", kr.primary)
+	}
+
+	if kr.indexKey, err = decodeKey(f.BlindIndexKey); err != nil {
+		return nil, fmt.Errorf("keyring blindIndexKey: %w", err)
+	}
+	return kr, nil
+}
+
+func decodeKey(s string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(raw))
+	}
+	return raw, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PrimaryID возвращает идентификатор текущего основного ключа
+func (kr *Keyring) PrimaryID() string {
+	return kr.primary
+}
+
+// NewEnvelope генерирует новый DEK и оборачивает его основным ключом
+func (kr *Keyring) NewEnvelope() (*Envelope, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	return kr.wrap(dek)
+}
+
+// Open разворачивает DEK, сохранённый вместе с записью
+func (kr *Keyring) Open(keyID string, wrapped []byte) (*Envelope, error) {
+	kek, ok := kr.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	dek, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return kr.wrapWith(keyID, wrapped, dek)
+}
+
+// Rewrap оборачивает DEK конверта текущим основным ключом; зашифрованные поля остаются валидными
+func (kr *Keyring) Rewrap(env *Envelope) (*Envelope, error) {
+	if env.KeyID == kr.primary {
+		return env, nil
+	}
+	return kr.wrap(env.dek)
+}
+
+func (kr *Keyring) wrap(dek []byte) (*Envelope, error) {
+	wrapped, err := seal(kr.keys[kr.primary], dek, []byte(kr.primary))
+	if err != nil {
+		return nil, err
+	}
+	return kr.wrapWith(kr.primary, wrapped, dek)
+}
+
+func (kr *Keyring) wrapWith(keyID string, wrapped, dek []byte) (*Envelope, error) {
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{KeyID: keyID, WrappedKey: wrapped, dek: dek, aead: aead}, nil
+}
+
+// BlindIndex вычисляет слепой индекс значения: HMAC-SHA256 от нормализованной строки.
+// kind разделяет пространства индексов (например, "email" и "phone")
+func (kr *Keyring) BlindIndex(kind, value string) []byte {
+	mac := hmac.New(sha256.New, kr.indexKey)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(Normalize(kind, value)))
+	return mac.Sum(nil)
+}
+
+// Normalize приводит значение к каноничному виду, чтобы поиск не зависел от регистра и форматирования
+func Normalize(kind, value string) string {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case "email":
+		return strings.ToLower(value)
+	case "phone":
+		return strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, value)
+	}
+	return value
+}
+
+// Envelope — развёрнутый DEK записи
+type Envelope struct {
+	KeyID      string
+	WrappedKey []byte
+	dek        []byte
+	aead       cipher.AEAD
+}
+
+// Seal шифрует значение поля; имя поля используется как associated data,
+// чтобы зашифрованные значения нельзя было переставить между полями
+func (e *Envelope) Seal(field, plaintext string) (string, error) {
+	ct, err := seal(e.aead, []byte(plaintext), []byte(field))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ct), nil
+}
+
+// OpenField расшифровывает значение поля
+func (e *Envelope) OpenField(field, ciphertext string) (string, error) {
+	ct, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", field, err)
+	}
+	pt, err := open(e.aead, ct, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", field, err)
+	}
+	return string(pt), nil
+}
+
+// SealBytes шифрует произвольные данные; name используется как associated data
+func (e *Envelope) SealBytes(name string, plaintext []byte) ([]byte, error) {
+	return seal(e.aead, plaintext, []byte(name))
+}
+
+// OpenBytes расшифровывает данные, зашифрованные SealBytes
+func (e *Envelope) OpenBytes(name string, data []byte) ([]byte, error) {
+	return open(e.aead, data, []byte(name))
+}
+
+// seal возвращает nonce||ciphertext
+func seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ct := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ct, ad)
+}
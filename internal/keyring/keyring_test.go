@@ -0,0 +1,204 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func randomKey(t *testing.T) string {
+	t.Helper()
+	b := make([]byte, keySize)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// writeKeyring записывает файл ключей с ключами keys (id → ключ) и основным primary
+func writeKeyring(t *testing.T, primary string, keys map[string]string, indexKey string) string {
+	t.Helper()
+	var f keyringFile
+	f.Primary, f.BlindIndexKey = primary, indexKey
+	for id, key := range keys {
+		f.Keys = append(f.Keys, struct {
+			ID  string `json:"id"`
+			Key string `json:"key"`
+		}{id, key})
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	k1, index := randomKey(t), randomKey(t)
+	tests := []struct {
+		name    string
+		primary string
+		keys    map[string]string
+		index   string
+		wantErr bool
+	}{
+		{"valid", "k1", map[string]string{"k1": k1}, index, false},
+		{"primary not defined", "k2", map[string]string{"k1": k1}, index, true},
+		{"short key", "k1", map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte("short"))}, index, true},
+		{"not base64", "k1", map[string]string{"k1": "%%%"}, index, true},
+		{"no blind index key", "k1", map[string]string{"k1": k1}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeKeyring(t, tt.primary, tt.keys, tt.index))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	kr, err := Load(writeKeyring(t, "k1", map[string]string{"k1": randomKey(t)}, randomKey(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := kr.NewEnvelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := env.Seal("email", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Запись читается заново по сохранённым key_id и обёрнутому DEK
+	opened, err := kr.Open(env.KeyID, env.WrappedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(sealed)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name       string
+		field      string
+		ciphertext string
+		want       string
+		wantErr    bool
+	}{
+		{"same field", "email", sealed, "alice@example.com", false},
+		{"value moved to another field", "phone", sealed, "", true},
+		{"tampered ciphertext", "email", string(tampered), "", true},
+		{"not base64", "email", "%%%", "", true},
+		{"too short", "email", base64.StdEncoding.EncodeToString([]byte{1, 2}), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := opened.OpenField(tt.field, tt.ciphertext)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenField error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("OpenField = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	data, err := env.SealBytes("snapshot", []byte{0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := opened.OpenBytes("snapshot", data); err != nil || !bytes.Equal(got, []byte{0, 1, 2}) {
+		t.Fatalf("OpenBytes = %v, %v", got, err)
+	}
+	if _, err = opened.OpenBytes("other", data); err == nil {
+		t.Fatal("OpenBytes with another name succeeded")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, newKey, index := randomKey(t), randomKey(t), randomKey(t)
+	before, err := Load(writeKeyring(t, "2025-01", map[string]string{"2025-01": oldKey}, index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := before.NewEnvelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := env.Seal("name", "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := Load(writeKeyring(t, "2026-10", map[string]string{"2025-01": oldKey, "2026-10": newKey}, index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := after.Open(env.KeyID, env.WrappedKey)
+	if err != nil {
+		t.Fatalf("old record is unreadable after rotation: %v", err)
+	}
+	rewrapped, err := after.Rewrap(opened)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrapped.KeyID != "2026-10" {
+		t.Fatalf("Rewrap key id = %q, want the new primary", rewrapped.KeyID)
+	}
+	if again, err := after.Rewrap(rewrapped); err != nil || again != rewrapped {
+		t.Fatalf("Rewrap of an up-to-date envelope = %v, %v; want it unchanged", again, err)
+	}
+
+	// Поля, зашифрованные до ротации, читаются через перешифрованный DEK
+	reopened, err := after.Open(rewrapped.KeyID, rewrapped.WrappedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.OpenField("name", sealed); err != nil || got != "Alice" {
+		t.Fatalf("OpenField after rewrap = %q, %v", got, err)
+	}
+
+	// Ключ, выведенный из файла, больше не открывает записи
+	retired, err := Load(writeKeyring(t, "2026-10", map[string]string{"2026-10": newKey}, index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = retired.Open(env.KeyID, env.WrappedKey); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Open with a retired key: %v, want ErrUnknownKey", err)
+	}
+	// Обёрнутый DEK привязан к id ключа
+	if _, err = after.Open("2026-10", env.WrappedKey); err == nil {
+		t.Fatal("DEK wrapped by one key was opened with another")
+	}
+
+	// Слепой индекс не зависит от ротации KEK
+	if !bytes.Equal(before.BlindIndex("email", "A@x.io"), after.BlindIndex("email", " a@x.io ")) {
+		t.Fatal("blind index changed after rotation")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		kind, value, want string
+	}{
+		{"email", "  Alice@Example.COM ", "alice@example.com"},
+		{"phone", "+7 (999) 123-45-67", "79991234567"},
+		{"name", " Alice ", "Alice"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.kind, tt.value); got != tt.want {
+			t.Errorf("Normalize(%q, %q) = %q, want %q", tt.kind, tt.value, got, tt.want)
+		}
+	}
+}
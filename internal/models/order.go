@@ -1,41 +1,48 @@
 package models
 
-import "time"
+import (
+	"WBTechL0/internal/money"
+	"time"
+)
 
 // Order структура для заказа
 type Order struct {
-	OrderUID          string   `json:"order_uid"`
-	TrackNumber       string   `json:"track_number"`
-	Entry             string   `json:"entry"`
-	Delivery          Delivery `json:"delivery"`
-	Payment           Payment  `json:"payment"`
-	Items             []Item   `json:"items"`
-	Locale            string   `json:"locale"`
-	InternalSignature string   `json:"internal_signature"`
-	CustomerID        string   `json:"customer_id"`
-	DeliveryService   string   `json:"delivery_service"`
-	Shardkey          string   `json:"shardkey"`
-	SmID              int      `json:"sm_id"`
-	DateCreated       time.Time   `json:"date_created"`
-	OofShard          string   `json:"oof_shard"`
+	OrderUID          string    `json:"order_uid"`
+	TrackNumber       string    `json:"track_number"`
+	Entry             string    `json:"entry"`
+	Delivery          Delivery  `json:"delivery"`
+	Payment           Payment   `json:"payment"`
+	Items             []Item    `json:"items"`
+	Locale            string    `json:"locale"`
+	InternalSignature string    `json:"internal_signature"`
+	CustomerID        string    `json:"customer_id"`
+	DeliveryService   string    `json:"delivery_service"`
+	Shardkey          string    `json:"shardkey"`
+	SmID              int       `json:"sm_id"`
+	DateCreated       time.Time `json:"date_created"`
+	OofShard          string    `json:"oof_shard"`
 }
 
 // Delivery структура для доставки
+// Поля с тегом redact маскируются в логах и в ответах для ролей без доступа к персональным данным
 type Delivery struct {
-	Name    string `json:"name"  `
-	Phone   string `json:"phone"`
-	Zip     string `json:"zip"`
+	Name    string `json:"name" redact:"name"`
+	Phone   string `json:"phone" redact:"phone"`
+	Zip     string `json:"zip" redact:"full"`
 	City    string `json:"city"`
-	Address string `json:"address"`
+	Address string `json:"address" redact:"full"`
 	Region  string `json:"region"`
-	Email   string `json:"email"`
+	Email   string `json:"email" redact:"email"`
 }
 
+// ErasedValue — значение, которым заменяются персональные данные после запроса на удаление
+const ErasedValue = "[erased]"
+
 // Payment структура для платежа
 type Payment struct {
-	Transaction  string `json:"transaction"`
-	RequestID    string `json:"request_id"`
-	Currency     string `json:"currency"`
+	Transaction  string `json:"transaction" redact:"partial"`
+	RequestID    string `json:"request_id" redact:"partial"`
+	Currency     string `json:"currency" validate:"required,currency"` // Код ISO 4217; сохраняется в верхнем регистре
 	Provider     string `json:"provider"`
 	Amount       int    `json:"amount"`
 	PaymentDT    int64  `json:"payment_dt"`
@@ -45,6 +52,15 @@ type Payment struct {
 	CustomFee    int    `json:"custom_fee"`
 }
 
+// Money переводит сумму из платежа или товаров заказа (в целых единицах валюты) в money.Money
+func (p Payment) Money(amount int) money.Money {
+	c, ok := money.LookupCurrency(p.Currency)
+	if !ok {
+		c = money.Currency{Code: p.Currency, Exponent: 2}
+	}
+	return money.FromMajor(amount, c)
+}
+
 // Item структура для товара
 type Item struct {
 	ChrtID      int    `json:"chrt_id"`
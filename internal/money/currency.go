@@ -0,0 +1,60 @@
+package money
+
+import "strings"
+
+// Currency — валюта ISO 4217
+type Currency struct {
+	Code     string
+	Exponent int    // Количество знаков после запятой (минорных единиц)
+	Symbol   string // Пусто — при форматировании используется код
+}
+
+// Активные коды ISO 4217. Для валют, которых нет в exponents, минорных единиц две
+const isoCodes = `AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BOV BRL BSD BTN BWP
+BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU CRC CUC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL
+GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK
+LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN
+PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SLL SOS SRD SSP STN SVC SYP SZL THB TJS TMT
+TND TOP TRY TTD TWD TZS UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV WST XAF XCD XDR XOF XPF XSU XUA YER ZAR ZMW
+ZWL`
+
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0, "RWF": 0,
+	"UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0, "XDR": 0, "XSU": 0, "XUA": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+var symbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "RUB": "₽", "KZT": "₸", "UAH": "₴",
+	"BYN": "Br", "TRY": "₺", "INR": "₹", "KRW": "₩", "ILS": "₪", "AMD": "֏", "GEL": "₾", "UZS": "сўм",
+}
+
+var currencies = func() map[string]Currency {
+	m := make(map[string]Currency)
+	for _, code := range strings.Fields(isoCodes) {
+		exp, ok := exponents[code]
+		if !ok {
+			exp = 2
+		}
+		m[code] = Currency{Code: code, Exponent: exp, Symbol: symbols[code]}
+	}
+	return m
+}()
+
+// NormalizeCode приводит код валюты к виду ISO 4217: без пробелов по краям, в верхнем регистре
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// LookupCurrency ищет валюту по коду ISO 4217 без учёта регистра
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currencies[NormalizeCode(code)]
+	return c, ok
+}
+
+// IsValidCode сообщает, является ли code действующим кодом ISO 4217; регистр, как и в LookupCurrency, не учитывается
+func IsValidCode(code string) bool {
+	_, ok := LookupCurrency(code)
+	return ok
+}
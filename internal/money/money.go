@@ -0,0 +1,161 @@
+// Package money — денежные суммы в минорных единицах валюты, их форматирование и пересчёт по курсу.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrCurrencyMismatch — операция над суммами в разных валютах
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrOverflow — результат не помещается в int64 минорных единиц
+	ErrOverflow = errors.New("amount overflows int64")
+)
+
+// Money — сумма в минорных единицах (копейках, центах) валюты
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// FromMajor переводит сумму в целых единицах валюты (так суммы приходят в заказах) в Money
+func FromMajor(amount int, c Currency) Money {
+	return Money{Amount: int64(amount) * pow10(c.Exponent), Currency: c}
+}
+
+// Add складывает суммы в одной валюте; если сумма не помещается в int64 — ErrOverflow
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency.Code != o.Currency.Code {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency.Code, o.Currency.Code)
+	}
+	sum := m.Amount + o.Amount
+	// Переполнение: слагаемые одного знака, а сумма — другого
+	if (m.Amount >= 0) == (o.Amount >= 0) && (sum >= 0) != (m.Amount >= 0) {
+		return Money{}, fmt.Errorf("%w: %s + %s %s", ErrOverflow, m.Decimal(), o.Decimal(), m.Currency.Code)
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Convert пересчитывает сумму в валюту to по курсу rate (единиц to за одну единицу исходной валюты).
+// Результат округляется до минорной единицы to, половина — от нуля; если он не помещается в int64 — ErrOverflow
+func (m Money) Convert(rate *big.Rat, to Currency) (Money, error) {
+	v := new(big.Rat).SetFrac(big.NewInt(m.Amount), big.NewInt(pow10(m.Currency.Exponent)))
+	v.Mul(v, rate)
+	v.Mul(v, new(big.Rat).SetInt64(pow10(to.Exponent)))
+
+	num, den := v.Num(), v.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	// |2r| >= den — округляем от нуля
+	if new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s %s to %s", ErrOverflow, m.Decimal(), m.Currency.Code, to.Code)
+	}
+	return Money{Amount: q.Int64(), Currency: to}, nil
+}
+
+// String — каноничное представление: "1817.00 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency.Code
+}
+
+// Decimal — сумма без кода валюты и разделителей разрядов: "1817.00"
+func (m Money) Decimal() string {
+	return m.decimal(".", "")
+}
+
+// Format форматирует сумму по правилам локали заказа (разделители, положение символа валюты)
+func (m Money) Format(locale string) string {
+	f := formatFor(locale)
+	symbol := m.Currency.Symbol
+	if symbol == "" {
+		symbol = m.Currency.Code
+	}
+
+	num := m.decimal(f.decimal, f.group)
+	if f.symbolFirst {
+		if strings.HasPrefix(num, "-") {
+			return "-" + symbol + num[1:]
+		}
+		return symbol + num
+	}
+	return num + " " + symbol
+}
+
+// decimal печатает сумму с заданными разделителями дробной части и разрядов
+func (m Money) decimal(decimalSep, groupSep string) string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	unit := pow10(m.Currency.Exponent)
+	intPart := strconv.FormatInt(amount/unit, 10)
+	if groupSep != "" {
+		var b strings.Builder
+		for i, d := range intPart {
+			if i > 0 && (len(intPart)-i)%3 == 0 {
+				b.WriteString(groupSep)
+			}
+			b.WriteRune(d)
+		}
+		intPart = b.String()
+	}
+
+	if m.Currency.Exponent == 0 {
+		return sign + intPart
+	}
+	frac := fmt.Sprintf("%0*d", m.Currency.Exponent, amount%unit)
+	return sign + intPart + decimalSep + frac
+}
+
+// numberFormat — правила записи чисел в локали
+type numberFormat struct {
+	decimal     string
+	group       string
+	symbolFirst bool
+}
+
+var numberFormats = map[string]numberFormat{
+	"en": {decimal: ".", group: ",", symbolFirst: true},
+	"ru": {decimal: ",", group: " "},
+	"de": {decimal: ",", group: "."},
+	"fr": {decimal: ",", group: " "},
+	"es": {decimal: ",", group: "."},
+	"it": {decimal: ",", group: "."},
+	"kk": {decimal: ",", group: " "},
+	"uk": {decimal: ",", group: " "},
+	"be": {decimal: ",", group: " "},
+	"tr": {decimal: ",", group: ".", symbolFirst: true},
+	"zh": {decimal: ".", group: ",", symbolFirst: true},
+	"ja": {decimal: ".", group: ",", symbolFirst: true},
+}
+
+// formatFor выбирает формат по языку локали ("ru", "ru-RU", "ru_RU"); по умолчанию — английский
+func formatFor(locale string) numberFormat {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	if f, ok := numberFormats[lang]; ok {
+		return f
+	}
+	return numberFormats["en"]
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,132 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func mustCurrency(t *testing.T, code string) Currency {
+	t.Helper()
+	c, ok := LookupCurrency(code)
+	if !ok {
+		t.Fatalf("unknown currency %s", code)
+	}
+	return c
+}
+
+func TestConvert(t *testing.T) {
+	usd, rub, jpy, kwd := mustCurrency(t, "USD"), mustCurrency(t, "RUB"), mustCurrency(t, "JPY"), mustCurrency(t, "KWD")
+	tests := []struct {
+		name    string
+		from    Money
+		rate    string
+		to      Currency
+		want    int64
+		wantErr error
+	}{
+		{"exact", Money{Amount: 1000, Currency: usd}, "92.5", rub, 92500, nil},
+		{"half rounds up", Money{Amount: 1, Currency: usd}, "0.5", rub, 1, nil},
+		{"below half rounds down", Money{Amount: 1, Currency: usd}, "0.49", rub, 0, nil},
+		{"negative half rounds away from zero", Money{Amount: -1, Currency: usd}, "0.5", rub, -1, nil},
+		{"negative below half", Money{Amount: -1, Currency: usd}, "0.49", rub, 0, nil},
+		{"to currency without minor units", Money{Amount: 1050, Currency: usd}, "1", jpy, 11, nil},
+		{"from currency without minor units", Money{Amount: 150, Currency: jpy}, "1/150", usd, 100, nil},
+		{"three minor digits", Money{Amount: 1, Currency: rub}, "0.0035", kwd, 0, nil},
+		{"three minor digits half", Money{Amount: 100, Currency: rub}, "0.0035", kwd, 4, nil},
+		{"overflow", Money{Amount: math.MaxInt64, Currency: usd}, "100", rub, 0, ErrOverflow},
+		{"negative overflow", Money{Amount: math.MinInt64, Currency: usd}, "2", rub, 0, ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, ok := new(big.Rat).SetString(tt.rate)
+			if !ok {
+				t.Fatalf("invalid rate %q", tt.rate)
+			}
+			got, err := tt.from.Convert(rate, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Convert error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Amount != tt.want {
+				t.Fatalf("Convert = %d, want %d", got.Amount, tt.want)
+			}
+			if err == nil && got.Currency.Code != tt.to.Code {
+				t.Fatalf("Convert currency = %s, want %s", got.Currency.Code, tt.to.Code)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	usd, rub, jpy, bhd := mustCurrency(t, "USD"), mustCurrency(t, "RUB"), mustCurrency(t, "JPY"), mustCurrency(t, "BHD")
+	tests := []struct {
+		m      Money
+		locale string
+		want   string
+	}{
+		{Money{Amount: 181700, Currency: usd}, "en", "$1,817.00"},
+		{Money{Amount: -181705, Currency: usd}, "en-US", "-$1,817.05"},
+		{Money{Amount: 123456789, Currency: rub}, "ru_RU", "1\u00a0234\u00a0567,89\u00a0₽"},
+		{Money{Amount: 5, Currency: rub}, "de", "0,05\u00a0₽"},
+		{Money{Amount: 1000000, Currency: jpy}, "ja", "¥1,000,000"},
+		{Money{Amount: 1500, Currency: bhd}, "xx", "BHD1.500"},
+		{Money{Amount: 100, Currency: Currency{Code: "XYZ", Exponent: 2}}, "fr", "1,00\u00a0XYZ"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Format(tt.locale); got != tt.want {
+			t.Errorf("Format(%v, %q) = %q, want %q", tt.m, tt.locale, got, tt.want)
+		}
+	}
+
+	if got := (Money{Amount: -5, Currency: usd}).String(); got != "-0.05 USD" {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func TestCurrencyCodes(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"USD", true},
+		{"usd", true},
+		{" Rub ", true},
+		{"XYZ", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		_, found := LookupCurrency(tt.code)
+		if valid := IsValidCode(tt.code); valid != tt.want || found != tt.want {
+			t.Errorf("%q: IsValidCode = %v, LookupCurrency found = %v, want %v", tt.code, valid, found, tt.want)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	usd, rub := mustCurrency(t, "USD"), mustCurrency(t, "RUB")
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    int64
+		wantErr error
+	}{
+		{"positive", Money{Amount: 150, Currency: usd}, Money{Amount: 250, Currency: usd}, 400, nil},
+		{"mixed signs", Money{Amount: math.MaxInt64, Currency: usd}, Money{Amount: -1, Currency: usd}, math.MaxInt64 - 1, nil},
+		{"max", Money{Amount: math.MaxInt64 - 1, Currency: usd}, Money{Amount: 1, Currency: usd}, math.MaxInt64, nil},
+		{"overflow", Money{Amount: math.MaxInt64, Currency: usd}, Money{Amount: 1, Currency: usd}, 0, ErrOverflow},
+		{"negative overflow", Money{Amount: math.MinInt64, Currency: usd}, Money{Amount: -1, Currency: usd}, 0, ErrOverflow},
+		{"currency mismatch", Money{Amount: 1, Currency: usd}, Money{Amount: 1, Currency: rub}, 0, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Add error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Amount != tt.want {
+				t.Fatalf("Add = %d, want %d", got.Amount, tt.want)
+			}
+		})
+	}
+}
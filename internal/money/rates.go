@@ -0,0 +1,65 @@
+package money
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"time"
+)
+
+// Rate — курс валюты к базовой: сколько единиц базовой валюты стоит одна единица Currency,
+// начиная с даты ValidFrom
+type Rate struct {
+	Currency  string
+	ValidFrom time.Time
+	Rate      *big.Rat
+}
+
+// LoadRates читает курсы из CSV-файла со строками "currency,valid_from,rate",
+// например "USD,2024-01-01,89.6883". Строки, начинающиеся с #, пропускаются
+func LoadRates(path string) ([]Rate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open exchange rates file: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 3
+	r.TrimLeadingSpace = true
+
+	var rates []Rate
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exchange rates file: %w", err)
+		}
+
+		line, _ := r.FieldPos(0)
+		code := NormalizeCode(rec[0])
+		// Заголовок CSV
+		if line == 1 && code == "CURRENCY" {
+			continue
+		}
+		if !IsValidCode(code) {
+			return nil, fmt.Errorf("exchange rates line %d: unknown currency %q", line, rec[0])
+		}
+		validFrom, err := time.Parse(time.DateOnly, rec[1])
+		if err != nil {
+			return nil, fmt.Errorf("exchange rates line %d: %w", line, err)
+		}
+		rate, ok := new(big.Rat).SetString(rec[2])
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("exchange rates line %d: invalid rate %q", line, rec[2])
+		}
+		rates = append(rates, Rate{Currency: code, ValidFrom: validFrom, Rate: rate})
+	}
+	return rates, nil
+}
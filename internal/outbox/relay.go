@@ -0,0 +1,92 @@
+// Package outbox публикует в Kafka события о заказах, записанные в таблицу outbox.
+package outbox
+
+import (
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/consumer"
+	"WBTechL0/internal/db/repository"
+	"context"
+	"encoding/json"
+	"github.com/IBM/sarama"
+	"log/slog"
+	"time"
+)
+
+// Relay — периодическая публикация событий из outbox. Доставка не реже одного раза:
+// при сбое между отправкой и отметкой в бд событие будет отправлено повторно
+type Relay struct {
+	repo     *repository.Repo
+	producer sarama.SyncProducer
+	cfg      config.Outbox
+	clientID string
+	sl       *slog.Logger
+}
+
+// NewRelay создаёт Relay; clientID указывается продюсером в событиях
+func NewRelay(repo *repository.Repo, producer sarama.SyncProducer, cfg config.Outbox, clientID string, sl *slog.Logger) *Relay {
+	return &Relay{repo: repo, producer: producer, cfg: cfg, clientID: clientID, sl: sl}
+}
+
+// Run публикует события, пока не завершится ctx. Пока в outbox есть события, пачки идут подряд,
+// иначе outbox опрашивается с интервалом PollInterval
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+
+	for {
+		n, err := r.repo.RelayOutbox(ctx, r.cfg.BatchSize, r.publish)
+		if err != nil {
+			r.sl.Error("Failed to relay outbox events", "error", err)
+		} else if n > 0 {
+			r.sl.Debug("Outbox events published", "events", n)
+		}
+
+		if r.cfg.Retention > 0 && time.Since(lastPurge) > time.Hour {
+			if _, err = r.repo.PurgeOutbox(ctx, time.Now().Add(-r.cfg.Retention)); err == nil {
+				lastPurge = time.Now()
+			}
+		}
+
+		// Полная пачка — вероятно, есть ещё
+		if err == nil && n == r.cfg.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publish отправляет пачку событий в обёртке consumer.Envelope с ключом order_uid
+func (r *Relay) publish(events []repository.OutboxEvent) error {
+	msgs := make([]*sarama.ProducerMessage, len(events))
+	for i, e := range events {
+		value, err := json.Marshal(consumer.Envelope{
+			SchemaVersion: consumer.CurrentSchemaVersion,
+			EventType:     e.EventType,
+			Producer:      r.clientID,
+			Timestamp:     e.CreatedAt,
+			Payload:       e.Payload,
+		})
+		if err != nil {
+			return err
+		}
+		msgs[i] = &sarama.ProducerMessage{
+			Topic: r.cfg.Topic,
+			Key:   sarama.StringEncoder(e.OrderUID),
+			Value: sarama.ByteEncoder(value),
+			Headers: []sarama.RecordHeader{
+				{Key: []byte("content-type"), Value: []byte("application/json")},
+				{Key: []byte("event-type"), Value: []byte(e.EventType)},
+			},
+		}
+	}
+	return r.producer.SendMessages(msgs)
+}
@@ -0,0 +1,74 @@
+// Package reconcile проверяет согласованность сумм заказа.
+package reconcile
+
+import (
+	"WBTechL0/internal/models"
+	"fmt"
+	"strings"
+)
+
+// Mode — что делать с заказом, не прошедшим проверки
+type Mode string
+
+const (
+	ModeReject Mode = "reject" // Заказ не сохраняется
+	ModeFlag   Mode = "flag"   // Заказ сохраняется, расхождения записываются в бд и попадают в отчёт
+	ModeWarn   Mode = "warn"   // Заказ сохраняется, расхождения только логируются
+)
+
+// ParseMode разбирает режим из конфига
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeReject, ModeFlag, ModeWarn:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown reconciliation mode %q", s)
+}
+
+// Названия проверок
+const (
+	CheckGoodsTotal = "goods_total" // Payment.GoodsTotal = сумма Item.TotalPrice
+	CheckItemTotal  = "item_total"  // Item.TotalPrice = Price за вычетом Sale процентов
+	CheckAmount     = "amount"      // Payment.Amount = GoodsTotal + DeliveryCost + CustomFee
+)
+
+// Issue — найденное расхождение
+type Issue struct {
+	Check    string `json:"check"`
+	Item     int    `json:"item,omitempty"` // Номер товара (с 1) для проверки item_total
+	Expected int    `json:"expected"`
+	Actual   int    `json:"actual"`
+}
+
+func (i Issue) String() string {
+	if i.Item > 0 {
+		return fmt.Sprintf("%s (item %d): expected %d, got %d", i.Check, i.Item, i.Expected, i.Actual)
+	}
+	return fmt.Sprintf("%s: expected %d, got %d", i.Check, i.Expected, i.Actual)
+}
+
+// Check проверяет инварианты сумм заказа и возвращает найденные расхождения
+func Check(order models.Order) []Issue {
+	var issues []Issue
+
+	goodsTotal := 0
+	for i, item := range order.Items {
+		goodsTotal += item.TotalPrice
+
+		// Цена со скидкой может быть дробной: допускаем округление в любую сторону
+		discounted100 := item.Price * (100 - item.Sale)
+		if diff := item.TotalPrice*100 - discounted100; diff <= -100 || diff >= 100 {
+			issues = append(issues, Issue{Check: CheckItemTotal, Item: i + 1, Expected: discounted100 / 100, Actual: item.TotalPrice})
+		}
+	}
+
+	p := order.Payment
+	if p.GoodsTotal != goodsTotal {
+		issues = append(issues, Issue{Check: CheckGoodsTotal, Expected: goodsTotal, Actual: p.GoodsTotal})
+	}
+	if expected := p.GoodsTotal + p.DeliveryCost + p.CustomFee; p.Amount != expected {
+		issues = append(issues, Issue{Check: CheckAmount, Expected: expected, Actual: p.Amount})
+	}
+
+	return issues
+}
@@ -0,0 +1,87 @@
+package reconcile
+
+import (
+	"WBTechL0/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	order := func(payment models.Payment, items ...models.Item) models.Order {
+		return models.Order{Payment: payment, Items: items}
+	}
+	tests := []struct {
+		name  string
+		order models.Order
+		want  []Issue
+	}{
+		{
+			"consistent",
+			order(models.Payment{Amount: 1817, DeliveryCost: 1500, GoodsTotal: 317},
+				models.Item{Price: 453, Sale: 30, TotalPrice: 317}),
+			nil,
+		},
+		{
+			"fractional discount rounded either way",
+			order(models.Payment{Amount: 67, GoodsTotal: 67},
+				models.Item{Price: 99, Sale: 33, TotalPrice: 66}, models.Item{Price: 1, Sale: 50, TotalPrice: 1}),
+			nil,
+		},
+		{"no items", order(models.Payment{}), nil},
+		{
+			"item total off by more than rounding",
+			order(models.Payment{Amount: 400, GoodsTotal: 400},
+				models.Item{Price: 453, Sale: 30, TotalPrice: 400}),
+			[]Issue{{Check: CheckItemTotal, Item: 1, Expected: 317, Actual: 400}},
+		},
+		{
+			"goods total differs from items",
+			order(models.Payment{Amount: 300, GoodsTotal: 300},
+				models.Item{Price: 100, TotalPrice: 100}, models.Item{Price: 100, TotalPrice: 100}),
+			[]Issue{{Check: CheckGoodsTotal, Expected: 200, Actual: 300}},
+		},
+		{
+			"amount differs from components",
+			order(models.Payment{Amount: 1000, GoodsTotal: 100, DeliveryCost: 50, CustomFee: 5},
+				models.Item{Price: 100, TotalPrice: 100}),
+			[]Issue{{Check: CheckAmount, Expected: 155, Actual: 1000}},
+		},
+		{
+			"several issues",
+			order(models.Payment{Amount: 1, GoodsTotal: 0},
+				models.Item{Price: 10, Sale: 0, TotalPrice: 5}),
+			[]Issue{
+				{Check: CheckItemTotal, Item: 1, Expected: 10, Actual: 5},
+				{Check: CheckGoodsTotal, Expected: 5, Actual: 0},
+				{Check: CheckAmount, Expected: 0, Actual: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Check(tt.order); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Check = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Mode
+		wantErr bool
+	}{
+		{"reject", ModeReject, false},
+		{" FLAG ", ModeFlag, false},
+		{"Warn", ModeWarn, false},
+		{"", "", true},
+		{"ignore", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMode(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMode(%q) = %q, %v; want %q, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
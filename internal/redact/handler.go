@@ -0,0 +1,58 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler — обёртка над slog.Handler, маскирующая значения атрибутов,
+// типы которых содержат поля с тегом redact
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler оборачивает next
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	masked := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(maskAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, masked)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = maskAttr(a)
+	}
+	return &Handler{next: h.next.WithAttrs(masked)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
+
+func maskAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindAny:
+		a.Value = slog.AnyValue(Value(a.Value.Any()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		masked := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			masked[i] = maskAttr(ga)
+		}
+		a.Value = slog.GroupValue(masked...)
+	}
+	return a
+}
@@ -0,0 +1,178 @@
+// Package redact маскирует чувствительные поля структур, отмеченные тегом `redact`.
+//
+// Поддерживаемые значения тега:
+//
+//	full    — значение полностью заменяется на "***"
+//	partial — остаются последние 4 символа: "***1234"
+//	name    — остаётся первая буква: "И***"
+//	email   — остаются первая буква и домен: "i***@example.com"
+//	phone   — остаются последние 2 цифры: "***67"
+//
+// Нестроковые поля с тегом обнуляются.
+package redact
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	TagName = "redact"
+	hidden  = "***"
+)
+
+// Mask возвращает копию v, в которой замаскированы все поля с тегом redact,
+// включая вложенные структуры, указатели и слайсы. Исходное значение не меняется
+func Mask[T any](v T) T {
+	rv := reflect.ValueOf(&v).Elem()
+	maskValue(rv)
+	return v
+}
+
+// Value — то же, что Mask, для значений неизвестного на этапе компиляции типа
+func Value(v any) any {
+	if v == nil || !Sensitive(reflect.TypeOf(v)) {
+		return v
+	}
+	rv := reflect.New(reflect.TypeOf(v)).Elem()
+	rv.Set(reflect.ValueOf(v))
+	maskValue(rv)
+	return rv.Interface()
+}
+
+// String маскирует строку по заданному правилу
+func String(s, rule string) string {
+	if s == "" {
+		return s
+	}
+	switch rule {
+	case "partial":
+		if utf8.RuneCountInString(s) <= 8 {
+			return hidden
+		}
+		r := []rune(s)
+		return hidden + string(r[len(r)-4:])
+	case "name":
+		r, _ := utf8.DecodeRuneInString(s)
+		return string(r) + hidden
+	case "email":
+		local, domain, found := strings.Cut(s, "@")
+		if !found || local == "" {
+			return hidden
+		}
+		r, _ := utf8.DecodeRuneInString(local)
+		return string(r) + hidden + "@" + domain
+	case "phone":
+		digits := make([]rune, 0, len(s))
+		for _, r := range s {
+			if r >= '0' && r <= '9' {
+				digits = append(digits, r)
+			}
+		}
+		if len(digits) < 6 {
+			return hidden
+		}
+		return hidden + string(digits[len(digits)-2:])
+	}
+	return hidden
+}
+
+// sensitiveTypes кэширует результат Sensitive по типам
+var sensitiveTypes sync.Map // map[reflect.Type]bool
+
+// Sensitive сообщает, содержит ли тип (в том числе во вложенных полях) поля с тегом redact
+func Sensitive(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	if v, ok := sensitiveTypes.Load(t); ok {
+		return v.(bool)
+	}
+	// В кэш попадает только окончательный результат: промежуточные, посчитанные
+	// при обходе рекурсивного типа, могут быть неполными
+	v, _ := sensitiveTypes.LoadOrStore(t, computeSensitive(t, make(map[reflect.Type]bool)))
+	return v.(bool)
+}
+
+// computeSensitive обходит тип; visiting — типы, которые сейчас обходятся выше по стеку.
+// Повторная встреча такого типа ничего не добавляет: его поля и так будут проверены
+func computeSensitive(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if v, ok := sensitiveTypes.Load(t); ok {
+		return v.(bool)
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return computeSensitive(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if _, ok := f.Tag.Lookup(TagName); ok || computeSensitive(f.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maskValue маскирует v на месте. Слайсы и указатели перед изменением копируются,
+// чтобы не затронуть данные, на которые ссылается оригинал
+func maskValue(v reflect.Value) {
+	if !Sensitive(v.Type()) {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(v.Elem())
+		maskValue(cp.Elem())
+		v.Set(cp)
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(cp, v)
+		for i := 0; i < cp.Len(); i++ {
+			maskValue(cp.Index(i))
+		}
+		v.Set(cp)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			maskValue(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			rule, ok := f.Tag.Lookup(TagName)
+			if !ok {
+				maskValue(fv)
+				continue
+			}
+			if fv.Kind() == reflect.String {
+				fv.SetString(String(fv.String(), rule))
+			} else {
+				fv.SetZero()
+			}
+		}
+	}
+}
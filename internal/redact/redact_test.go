@@ -0,0 +1,138 @@
+package redact
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type node struct {
+	Next   *node
+	Secret string `redact:"full"`
+}
+
+type tree struct {
+	Children []tree
+	Parent   *tree
+	Leaf     node
+}
+
+func TestSensitiveConcurrentFirstUse(t *testing.T) {
+	// Новые типы на каждый прогон, чтобы первый вызов действительно шёл без кэша
+	types := []reflect.Type{
+		reflect.TypeOf(struct {
+			A node
+		}{}),
+		reflect.TypeOf(struct {
+			B []*tree
+		}{}),
+	}
+	for _, typ := range types {
+		var wg sync.WaitGroup
+		results := make([]bool, 64)
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = Sensitive(typ)
+			}()
+		}
+		wg.Wait()
+		for i, got := range results {
+			if !got {
+				t.Fatalf("%v: call %d reported the type as not sensitive", typ, i)
+			}
+		}
+	}
+}
+
+func TestSensitiveRecursiveTypes(t *testing.T) {
+	type plain struct {
+		Next *plain
+		Name string
+	}
+	tests := []struct {
+		typ  reflect.Type
+		want bool
+	}{
+		{reflect.TypeOf(node{}), true},
+		{reflect.TypeOf(tree{}), true},
+		{reflect.TypeOf(&tree{}), true},
+		{reflect.TypeOf(plain{}), false},
+		{reflect.TypeOf([]plain{}), false},
+		{reflect.TypeOf(0), false},
+	}
+	for _, tt := range tests {
+		if got := Sensitive(tt.typ); got != tt.want {
+			t.Errorf("Sensitive(%v) = %v, want %v", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		value, rule, want string
+	}{
+		{"", "full", ""},
+		{"Ploshad Mira 15", "full", "***"},
+		{"b563feb7b2b84b6test", "partial", "***test"},
+		{"12345678", "partial", "***"},
+		{"Иван Иванов", "name", "И***"},
+		{"test@gmail.com", "email", "t***@gmail.com"},
+		{"not-an-email", "email", "***"},
+		{"@gmail.com", "email", "***"},
+		{"+7 (999) 123-45-67", "phone", "***67"},
+		{"12-34", "phone", "***"},
+		{"anything", "unknown-rule", "***"},
+	}
+	for _, tt := range tests {
+		if got := String(tt.value, tt.rule); got != tt.want {
+			t.Errorf("String(%q, %q) = %q, want %q", tt.value, tt.rule, got, tt.want)
+		}
+	}
+}
+
+type contact struct {
+	Email string `redact:"email"`
+	City  string
+}
+
+type account struct {
+	Token    string `redact:"partial"`
+	PIN      int    `redact:"full"`
+	Primary  *contact
+	Contacts []contact
+	Note     string
+}
+
+func TestMask(t *testing.T) {
+	orig := account{
+		Token:    "secret-token-1234",
+		PIN:      4321,
+		Primary:  &contact{Email: "alice@example.com", City: "Moscow"},
+		Contacts: []contact{{Email: "bob@example.com", City: "Kazan"}},
+		Note:     "visible",
+	}
+	got := Mask(orig)
+
+	want := account{
+		Token:    "***1234",
+		Primary:  &contact{Email: "a***@example.com", City: "Moscow"},
+		Contacts: []contact{{Email: "b***@example.com", City: "Kazan"}},
+		Note:     "visible",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Mask = %+v, want %+v", got, want)
+	}
+	// Оригинал, включая данные по указателям и в слайсах, не меняется
+	if orig.Token != "secret-token-1234" || orig.PIN != 4321 || orig.Primary.Email != "alice@example.com" || orig.Contacts[0].Email != "bob@example.com" {
+		t.Fatalf("Mask modified the original: %+v", orig)
+	}
+
+	if v := Value(orig).(account); v.Token != "***1234" {
+		t.Fatalf("Value did not mask: %+v", v)
+	}
+	if v := Value("plain"); v != "plain" {
+		t.Fatalf("Value(string) = %v", v)
+	}
+}
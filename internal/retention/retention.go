@@ -0,0 +1,49 @@
+package retention
+
+import (
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/service"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job — периодическая задача переноса старых заказов в архив
+type Job struct {
+	srv *service.OrderService
+	cfg config.Retention
+	sl  *slog.Logger
+}
+
+// New создаёт задачу хранения
+func New(srv *service.OrderService, cfg config.Retention, sl *slog.Logger) *Job {
+	return &Job{srv: srv, cfg: cfg, sl: sl}
+}
+
+// Run запускает задачу сразу и затем с интервалом из конфига, пока не завершится ctx
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Job) runOnce(ctx context.Context) {
+	before := time.Now().Add(-j.cfg.MaxAge)
+	start := time.Now()
+
+	n, err := j.srv.ArchiveOrders(ctx, before, j.cfg.BatchSize)
+	if err != nil {
+		j.sl.Error("Failed to archive orders", "before", before, "archived", n, "error", err)
+		return
+	}
+	j.sl.Info("Orders archived", "before", before, "archived", n, "took", time.Since(start))
+}
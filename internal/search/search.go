@@ -0,0 +1,69 @@
+// Package search — разбор поисковых запросов и подсветка совпадений для полнотекстового поиска заказов.
+package search
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxTerms ограничивает количество слов в запросе
+const maxTerms = 10
+
+// Terms разбивает запрос на слова в нижнем регистре, отбрасывая знаки препинания и повторы
+func Terms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+		if len(terms) == maxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// TSQuery строит запрос для to_tsquery: совпадение любого слова по префиксу ("ива:* | москв:*").
+// Ранжирование поднимает заказы, в которых совпало больше слов
+func TSQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t + ":*"
+	}
+	return strings.Join(parts, " | ")
+}
+
+// Highlight экранирует text и выделяет тегом <mark> слова, начинающиеся с одного из terms
+func Highlight(text string, terms []string) template.HTML {
+	if len(terms) == 0 || text == "" {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	re := regexp.MustCompile(`(?i)(^|[^\pL\pN])((?:` + strings.Join(quoted, "|") + `)[\pL\pN]*)`)
+
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[4], m[5]
+		b.WriteString(template.HTMLEscapeString(text[last:start]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[start:end]))
+		b.WriteString("</mark>")
+		last = end
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
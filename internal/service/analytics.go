@@ -0,0 +1,19 @@
+package service
+
+import (
+	"WBTechL0/internal/db/repository"
+	"context"
+	"time"
+)
+
+// OrderStats возвращает количество заказов и выручку в валюте отчётности по разрезу dimension
+// (day, delivery_service, region, provider, bank, brand) за дни с from по to включительно.
+// Данные берутся из представлений, пересчитываемых по расписанию, и могут отставать на интервал пересчёта
+func (srv *OrderService) OrderStats(ctx context.Context, dimension string, from, to time.Time) ([]repository.StatRow, error) {
+	return srv.Repo.GetStats(ctx, dimension, from, to, srv.BaseCurrency)
+}
+
+// RefreshAnalytics пересчитывает данные отчётов
+func (srv *OrderService) RefreshAnalytics(ctx context.Context) error {
+	return srv.Repo.RefreshAnalytics(ctx)
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/export"
+	"context"
+	"io"
+)
+
+// ExportOrders выгружает заказы в CSV в w, не загружая их в память целиком. Возвращает количество заказов
+func (srv *OrderService) ExportOrders(ctx context.Context, w io.Writer, filter repository.OrderFilter, opts export.Options) (int, error) {
+	n, err := export.Write(ctx, w, srv.Repo, filter, opts)
+	if err != nil {
+		srv.Sl.Error("Failed to export orders", "exported", n, "error", err)
+		return n, err
+	}
+	srv.Sl.Info("Orders exported", "orders", n, "per", opts.Per)
+	return n, nil
+}
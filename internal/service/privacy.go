@@ -0,0 +1,55 @@
+package service
+
+import (
+	"WBTechL0/internal/models"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrCustomerNotFound — у покупателя нет заказов
+var ErrCustomerNotFound = errors.New("customer not found")
+
+// CustomerExport — архив данных покупателя по запросу субъекта персональных данных
+type CustomerExport struct {
+	CustomerID string         `json:"customer_id"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Orders     []models.Order `json:"orders"`
+}
+
+// ExportCustomerData собирает все заказы покупателя вместе с персональными данными
+func (srv *OrderService) ExportCustomerData(ctx context.Context, customerID string) (*CustomerExport, error) {
+	orders, err := srv.Repo.GetOrdersByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, ErrCustomerNotFound
+	}
+
+	srv.Sl.Info("Customer data exported", "customer_id", customerID, "orders", len(orders))
+	return &CustomerExport{CustomerID: customerID, ExportedAt: time.Now().UTC(), Orders: orders}, nil
+}
+
+// EraseCustomerData обезличивает персональные данные покупателя в бд и убирает его заказы из кэша.
+// Финансовые данные (платежи, товары) сохраняются. Возвращает UID затронутых заказов
+func (srv *OrderService) EraseCustomerData(ctx context.Context, customerID string) ([]string, error) {
+	uids, err := srv.Repo.EraseCustomerData(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(uids) == 0 {
+		return nil, ErrCustomerNotFound
+	}
+
+	// Следующее чтение пойдёт в бд и получит уже обезличенные данные
+	for _, uid := range uids {
+		srv.Cache.Delete(uid)
+	}
+	if srv.OnErase != nil {
+		srv.OnErase()
+	}
+
+	srv.Sl.Info("Customer data erased", "customer_id", customerID, "orders", len(uids))
+	return uids, nil
+}
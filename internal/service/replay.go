@@ -0,0 +1,33 @@
+package service
+
+import (
+	"WBTechL0/internal/db/repository"
+	"WBTechL0/internal/models"
+	"context"
+)
+
+// ReplayOrder повторно принимает заказ: проверяет его как SaveOrder и сохраняет идемпотентно,
+// заменяя сохранённую версию, если она отличается. Ошибка CheckOrder означает, что заказ отклонён
+func (srv *OrderService) ReplayOrder(ctx context.Context, order models.Order) (repository.UpsertResult, error) {
+	issues, err := srv.CheckOrder(order)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := srv.Repo.UpsertOrder(ctx, order, srv.flagged(issues))
+	if err != nil {
+		return 0, err
+	}
+	if res == repository.Unchanged {
+		return res, nil
+	}
+
+	// Кэш обновляем по сохранённой версии: у неё могут быть удалены персональные данные
+	stored, err := srv.Repo.GetOrderByUID(ctx, order.OrderUID)
+	if err != nil {
+		srv.Cache.Delete(order.OrderUID)
+		return res, nil
+	}
+	srv.Cache.Set(*stored)
+	return res, nil
+}
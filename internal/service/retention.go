@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// ArchiveOrders переносит в архив заказы старше before пачками по batchSize и убирает их из кэша.
+// Возвращает общее количество перенесённых заказов
+func (srv *OrderService) ArchiveOrders(ctx context.Context, before time.Time, batchSize int) (int, error) {
+	total := 0
+	for {
+		uids, selected, err := srv.Repo.ArchiveOrdersBefore(ctx, before, batchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, uid := range uids {
+			srv.Cache.Delete(uid)
+		}
+		total += len(uids)
+
+		// Останавливаемся по выбранным, а не перенесённым: пропущенный заказ не означает, что старые кончились
+		if selected < batchSize || ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+	}
+}
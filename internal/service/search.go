@@ -0,0 +1,48 @@
+package service
+
+import (
+	"WBTechL0/internal/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SearchResult — заказ, найденный полнотекстовым поиском
+type SearchResult struct {
+	Order models.Order
+	Rank  float64
+}
+
+// SearchOrders ищет заказы по имени получателя, городу, региону, товарам и брендам.
+// Имя участвует в поиске только при includePII; при шифровании доставки имя ищется лишь по точному слову
+func (srv *OrderService) SearchOrders(ctx context.Context, query string, includePII bool, limit int) ([]SearchResult, error) {
+	hits, err := srv.Repo.SearchOrders(ctx, query, includePII, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		order := srv.GetOrder(hit.OrderUID)
+		if order == nil {
+			continue
+		}
+		results = append(results, SearchResult{Order: *order, Rank: hit.Rank})
+	}
+	return results, nil
+}
+
+// ErrUnknownContactKind — поиск по контакту поддерживает только email и phone
+var ErrUnknownContactKind = errors.New("unknown contact kind")
+
+// FindOrdersByContact ищет заказы по контакту получателя: kind — "email" или "phone".
+// Значение сравнивается в нормализованном виде: регистр email и форматирование телефона не важны
+func (srv *OrderService) FindOrdersByContact(ctx context.Context, kind, value string) ([]models.Order, error) {
+	switch kind {
+	case "email":
+		return srv.Repo.GetOrdersByEmail(ctx, value)
+	case "phone":
+		return srv.Repo.GetOrdersByPhone(ctx, value)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownContactKind, kind)
+}
@@ -4,49 +4,160 @@ import (
 	"WBTechL0/internal/cache"
 	"WBTechL0/internal/db/repository"
 	"WBTechL0/internal/models"
+	"WBTechL0/internal/money"
+	"WBTechL0/internal/reconcile"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/go-playground/validator/v10"
 	"log/slog"
+	"time"
 )
 
 type OrderService struct {
-	Sl    *slog.Logger
-	Cache *cache.Cache
-	Repo  *repository.Repo
+	Sl            *slog.Logger
+	Cache         *cache.Cache
+	Repo          *repository.Repo
+	BaseCurrency  money.Currency // Валюта отчётности
+	ReconcileMode reconcile.Mode // Что делать с заказами с несходящимися суммами
+	// OnErase вызывается после удаления персональных данных, когда заказы уже убраны из кэша:
+	// копии данных вне кэша и бд (снимок кэша на диске) должны исчезнуть вместе с ними
+	OnErase func()
 }
 
-func New(cache *cache.Cache, repo *repository.Repo, sl *slog.Logger) *OrderService {
-	return &OrderService{Cache: cache, Repo: repo, Sl: sl}
+func New(cache *cache.Cache, repo *repository.Repo, sl *slog.Logger, base money.Currency, reconcileMode reconcile.Mode) *OrderService {
+	return &OrderService{Cache: cache, Repo: repo, Sl: sl, BaseCurrency: base, ReconcileMode: reconcileMode}
 }
 
-func (srv *OrderService) SaveOrder(order models.Order) {
-	valid := ValidateOrder(order)
-	//valid := true
-	if !valid {
+// SaveOrder проверяет и сохраняет новый заказ. Ошибка CheckOrder означает, что заказ отклонён
+func (srv *OrderService) SaveOrder(ctx context.Context, order models.Order) error {
+	issues, err := srv.CheckOrder(order)
+	if errors.Is(err, ErrInvalidOrder) {
 		srv.Sl.Error("Invalid order", "order", order)
-	} else {
-		_ = srv.Repo.SaveOrder(context.Background(), order)
-		srv.Cache.Set(order)
+		return err
 	}
+	if err != nil {
+		srv.Sl.Error("Order rejected: inconsistent totals", "order_uid", order.OrderUID, "issues", issues)
+		return err
+	}
+	if len(issues) > 0 {
+		srv.Sl.Warn("Inconsistent order totals", "order_uid", order.OrderUID, "issues", issues)
+	}
+
+	if err := srv.Repo.SaveOrder(ctx, order, srv.flagged(issues)); err != nil {
+		return err
+	}
+	srv.Cache.Set(order)
+	return nil
 }
 
-func (srv *OrderService) GetOrder(uid string) *models.Order {
-	order, found := srv.Cache.Get(uid)
-	var err error
-	if !found {
-		order, err = srv.Repo.GetOrderByUID(context.Background(), uid)
+// flagged — расхождения, которые сохраняются вместе с заказом: только в режиме flag
+func (srv *OrderService) flagged(issues []reconcile.Issue) []reconcile.Issue {
+	if srv.ReconcileMode != reconcile.ModeFlag {
+		return nil
+	}
+	return issues
+}
+
+// Причины, по которым заказ не принимается
+var (
+	ErrInvalidOrder       = errors.New("invalid order")
+	ErrInconsistentTotals = errors.New("inconsistent order totals")
+)
+
+// CheckOrder валидирует заказ и сверяет его суммы. Возвращает найденные расхождения и ошибку,
+// если заказ нельзя сохранять: ErrInvalidOrder или, в режиме reject, ErrInconsistentTotals
+func (srv *OrderService) CheckOrder(order models.Order) ([]reconcile.Issue, error) {
+	if err := validate.Struct(order); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+
+	// Сверяем суммы заказа
+	issues := reconcile.Check(order)
+	if len(issues) > 0 && srv.ReconcileMode == reconcile.ModeReject {
+		return issues, fmt.Errorf("%w: %v", ErrInconsistentTotals, issues)
+	}
+	return issues, nil
+}
+
+// SaveOrders сохраняет пачку заказов, уже прошедших CheckOrder, одной транзакцией и кладёт сохранённые в кэш.
+// errs[i] — результат i-го заказа (repository.ErrOrderExists, если заказ уже есть); err — пачка не сохранена целиком
+func (srv *OrderService) SaveOrders(ctx context.Context, orders []models.Order) (errs []error, err error) {
+	issues := make([][]reconcile.Issue, len(orders))
+	for i, order := range orders {
+		issues[i] = srv.flagged(reconcile.Check(order))
+	}
+
+	errs, err = srv.Repo.SaveOrders(ctx, orders, issues)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, order := range orders {
+		if errs[i] == nil {
+			srv.Cache.Set(order)
+		}
 	}
+	return errs, nil
+}
+
+// ReconciliationReport возвращает заказы с несходящимися суммами, начиная с самых новых
+func (srv *OrderService) ReconciliationReport(ctx context.Context, limit int) ([]repository.FlaggedOrder, error) {
+	return srv.Repo.GetReconciliationIssues(ctx, limit)
+}
+
+// GetOrder возвращает заказ из кэша; при промахе читает его из базы и кладёт в кэш.
+// nil — заказа нет или его не удалось прочитать
+func (srv *OrderService) GetOrder(uid string) *models.Order {
+	order, err := srv.Cache.GetOrLoad(context.Background(), uid, srv.loadOrder)
 	if err != nil {
-		srv.Sl.Error("Error in retrieving order by uid", "uid", uid)
+		srv.Sl.Error("Error in retrieving order by uid", "uid", uid, "error", err)
+		return nil
 	}
 
-	srv.Sl.Info("Order retrieved successfully", "order_uid", uid, "order", order)
+	srv.Sl.Debug("Order retrieved successfully", "order_uid", uid, "order", order)
 	return order
 }
 
+// loadOrder читает заказ для кэша; отсутствие заказа не считается ошибкой, чтобы кэш его запомнил
+func (srv *OrderService) loadOrder(ctx context.Context, uid string) (*models.Order, error) {
+	order, err := srv.Repo.GetOrderByUID(ctx, uid)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, nil
+	}
+	return order, err
+}
+
+// CatchUpCache обновляет в кэше заказы, изменённые начиная с since: перечитывает их из базы,
+// удалённые убирает. Возвращает число обновлённых заказов
+func (srv *OrderService) CatchUpCache(ctx context.Context, since time.Time) (int, error) {
+	uids, err := srv.Repo.ChangedOrderUIDs(ctx, since)
+	if err != nil {
+		return 0, err
+	}
+	for i, uid := range uids {
+		srv.Cache.Delete(uid)
+		if _, err = srv.Cache.GetOrLoad(ctx, uid, srv.loadOrder); err != nil {
+			return i, err
+		}
+	}
+	return len(uids), nil
+}
+
+// validate — валидатор моделей с дополнительными правилами
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// currency — код ISO 4217 из таблицы money, без учёта регистра: так же, как его потом ищет LookupCurrency
+	_ = v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return money.IsValidCode(fl.Field().String())
+	})
+	return v
+}
+
 // ValidateOrder - Валидация модели
 func ValidateOrder(order models.Order) bool {
-	validate := validator.New()
 	err := validate.Struct(order)
 
 	if err != nil {
@@ -54,3 +165,15 @@ func ValidateOrder(order models.Order) bool {
 	}
 	return true
 }
+
+// ConvertToBase пересчитывает сумму в валюту отчётности по курсу на момент at
+func (srv *OrderService) ConvertToBase(ctx context.Context, m money.Money, at time.Time) (money.Money, error) {
+	if m.Currency.Code == srv.BaseCurrency.Code {
+		return m, nil
+	}
+	rate, err := srv.Repo.GetExchangeRate(ctx, m.Currency.Code, at)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return m.Convert(rate, srv.BaseCurrency)
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"WBTechL0/internal/models"
+	"testing"
+)
+
+func TestValidateOrderCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     bool
+	}{
+		{"USD", true},
+		{"usd", true},
+		{" Rub ", true},
+		{"SLE", true}, // Есть в таблице money, но не в iso4217 валидатора
+		{"XYZ", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		order := models.Order{Payment: models.Payment{Currency: tt.currency}}
+		if got := ValidateOrder(order); got != tt.want {
+			t.Errorf("ValidateOrder with currency %q = %v, want %v", tt.currency, got, tt.want)
+		}
+	}
+}
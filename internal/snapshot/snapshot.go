@@ -0,0 +1,263 @@
+// Package snapshot сохраняет кэш заказов на диск и восстанавливает его при старте,
+// догоняя базу только по заказам, изменённым после снимка.
+package snapshot
+
+import (
+	"WBTechL0/internal/cache"
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/keyring"
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/service"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// magic — сигнатура и версия формата файла. Дальше в gob: header, затем данные —
+// заказы в gob, сжатые gzip и, если загружены ключи, зашифрованные новым DEK
+const magic = "WBCS1"
+
+// overlap — запас при догонке: изменение отмечается временем начала транзакции,
+// и транзакция, начатая до снимка, могла закоммититься уже после него
+const overlap = 5 * time.Minute
+
+var (
+	// ErrStale — снимок старше допустимого, журнал изменений мог его уже не покрывать
+	ErrStale = errors.New("cache snapshot is too old")
+	// ErrInvalid — файл не является снимком кэша или повреждён
+	ErrInvalid = errors.New("invalid cache snapshot")
+	// ErrNoKeyring — снимок зашифрован, а ключи не загружены
+	ErrNoKeyring = errors.New("cache snapshot is encrypted, but keyring is not loaded")
+)
+
+type header struct {
+	TakenAt    time.Time
+	Orders     int
+	KeyID      string // Пусто — снимок не зашифрован
+	WrappedKey []byte
+}
+
+// Job — периодическое сохранение снимка кэша
+type Job struct {
+	srv *service.OrderService
+	kr  *keyring.Keyring
+	cfg config.Snapshot
+	sl  *slog.Logger
+	// mu не даёт Invalidate удалить файл, пока Save записывает снимок, прочитанный из кэша до удаления данных
+	mu sync.Mutex
+	// refresh просит Run сохранить снимок, не дожидаясь интервала
+	refresh chan struct{}
+}
+
+// New создаёт задачу снимков; kr == nil — снимок не шифруется
+func New(srv *service.OrderService, kr *keyring.Keyring, cfg config.Snapshot, sl *slog.Logger) *Job {
+	return &Job{srv: srv, kr: kr, cfg: cfg, sl: sl, refresh: make(chan struct{}, 1)}
+}
+
+// Run сохраняет снимок с интервалом из конфига, пока не завершится ctx, и чистит журнал изменений,
+// который уже не нужен ни одному пригодному снимку
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-j.refresh:
+		}
+
+		if err := j.Save(); err != nil {
+			j.sl.Error("Failed to save cache snapshot", "path", j.cfg.Path, "error", err)
+		}
+		if _, err := j.srv.Repo.PurgeOrderChanges(ctx, time.Now().Add(-j.cfg.MaxAge-overlap)); err != nil {
+			j.sl.Error("Failed to purge order changes", "error", err)
+		}
+	}
+}
+
+// Invalidate удаляет снимок, в котором могли остаться удалённые из бд данные, и просит Run
+// сохранить новый. Вызывается после удаления персональных данных, когда кэш уже очищен от них
+func (j *Job) Invalidate() error {
+	j.mu.Lock()
+	err := os.Remove(j.cfg.Path)
+	j.mu.Unlock()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	select {
+	case j.refresh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Save записывает снимок атомарно: через временный файл и переименование.
+// Пока кэш не прогрет, снимок не сохраняется: иначе следующий старт восстановит неполный кэш
+func (j *Job) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if state := j.srv.Cache.Warmup().State; state != cache.WarmupDone {
+		j.sl.Info("Cache snapshot skipped, cache is not warmed up", "state", state)
+		return nil
+	}
+
+	start := time.Now()
+	h := header{TakenAt: start}
+	orders := j.srv.Cache.Orders()
+	h.Orders = len(orders)
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if err := gob.NewEncoder(zw).Encode(orders); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	data := body.Bytes()
+
+	// В снимке персональные данные расшифрованы: на диске они не должны лежать открыто, если не лежат открыто в базе
+	if j.kr != nil {
+		env, err := j.kr.NewEnvelope()
+		if err != nil {
+			return err
+		}
+		if data, err = env.SealBytes(magic, data); err != nil {
+			return err
+		}
+		h.KeyID, h.WrappedKey = env.KeyID, env.WrappedKey
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.cfg.Path), 0o700); err != nil {
+		return err
+	}
+	tmp := j.cfg.Path + ".tmp"
+	if err := writeFile(tmp, h, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, j.cfg.Path); err != nil {
+		return err
+	}
+
+	j.sl.Info("Cache snapshot saved", "path", j.cfg.Path, "orders", h.Orders, "bytes", len(data), "took", time.Since(start))
+	return nil
+}
+
+func writeFile(path string, h header, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if _, err = w.WriteString(magic); err != nil {
+		return err
+	}
+	enc := gob.NewEncoder(w)
+	if err = enc.Encode(h); err != nil {
+		return err
+	}
+	if err = enc.Encode(data); err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Restore загружает снимок в кэш и догоняет базу по заказам, изменённым после него.
+// Возвращает число заказов в кэше. При ошибке догонки кэш очищается: устаревшие данные хуже пустого кэша
+func (j *Job) Restore(ctx context.Context) (int, error) {
+	h, orders, err := j.read()
+	if err != nil {
+		return 0, err
+	}
+	if age := time.Since(h.TakenAt); age > j.cfg.MaxAge {
+		return 0, fmt.Errorf("%w: taken %s ago", ErrStale, age.Round(time.Second))
+	}
+
+	for _, order := range orders {
+		j.srv.Cache.Set(order)
+	}
+
+	start := time.Now()
+	n, err := j.srv.CatchUpCache(ctx, h.TakenAt.Add(-overlap))
+	if err != nil {
+		j.srv.Cache.Clear()
+		return 0, fmt.Errorf("failed to catch up cache snapshot: %w", err)
+	}
+	j.sl.Info("Cache snapshot caught up", "taken_at", h.TakenAt, "changed_orders", n, "took", time.Since(start))
+	restored := j.srv.Cache.Len()
+	j.srv.Cache.MarkWarm(restored)
+	return restored, nil
+}
+
+func (j *Job) read() (header, []models.Order, error) {
+	var h header
+	f, err := os.Open(j.cfg.Path)
+	if err != nil {
+		return h, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	sig := make([]byte, len(magic))
+	if _, err = io.ReadFull(r, sig); err != nil || string(sig) != magic {
+		return h, nil, ErrInvalid
+	}
+	var data []byte
+	dec := gob.NewDecoder(r)
+	if err = dec.Decode(&h); err != nil {
+		return h, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err = dec.Decode(&data); err != nil {
+		return h, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if h.KeyID != "" {
+		if j.kr == nil {
+			return h, nil, fmt.Errorf("%w: key %s", ErrNoKeyring, h.KeyID)
+		}
+		env, err := j.kr.Open(h.KeyID, h.WrappedKey)
+		if err != nil {
+			return h, nil, err
+		}
+		if data, err = env.OpenBytes(magic, data); err != nil {
+			return h, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return h, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	var orders []models.Order
+	if err = gob.NewDecoder(zr).Decode(&orders); err != nil {
+		return h, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	// Контрольная сумма gzip проверяется только в конце потока, а gob может до него не дочитать
+	if _, err = io.Copy(io.Discard, zr); err != nil {
+		return h, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return h, orders, nil
+}
@@ -0,0 +1,241 @@
+package snapshot
+
+import (
+	"WBTechL0/internal/cache"
+	"WBTechL0/internal/config"
+	"WBTechL0/internal/keyring"
+	"WBTechL0/internal/models"
+	"WBTechL0/internal/money"
+	"WBTechL0/internal/reconcile"
+	"WBTechL0/internal/service"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testJob создаёт задачу снимков над прогретым кэшем с заказами orders
+func testJob(t *testing.T, kr *keyring.Keyring, orders ...models.Order) *Job {
+	t.Helper()
+	c := cache.New(config.Cache{})
+	for _, o := range orders {
+		c.Set(o)
+	}
+	c.MarkWarm(len(orders))
+	sl := slog.New(slog.NewTextHandler(io.Discard, nil))
+	base, _ := money.LookupCurrency("RUB")
+	srv := service.New(c, nil, sl, base, reconcile.ModeFlag)
+	cfg := config.Snapshot{Path: filepath.Join(t.TempDir(), "cache.snapshot"), MaxAge: 24 * time.Hour}
+	return New(srv, kr, cfg, sl)
+}
+
+func TestInvalidate(t *testing.T) {
+	j := testJob(t, nil, models.Order{OrderUID: "a", Delivery: models.Delivery{Name: "Alice"}})
+	if err := j.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := j.Invalidate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(j.cfg.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("snapshot still exists after Invalidate: %v", err)
+	}
+	select {
+	case <-j.refresh:
+	default:
+		t.Fatal("Invalidate did not request a new snapshot")
+	}
+
+	// Повторный вызов без файла и с уже запрошенным сохранением не ошибка и не блокируется
+	if err := j.Invalidate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Invalidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testKeyring(t *testing.T) *keyring.Keyring {
+	t.Helper()
+	key := func() string {
+		b := make([]byte, 32)
+		_, _ = rand.Read(b)
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	data := fmt.Sprintf(`{"primary":"k1","keys":[{"id":"k1","key":%q}],"blindIndexKey":%q}`, key(), key())
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	kr, err := keyring.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+func testOrders() []models.Order {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []models.Order{
+		{
+			OrderUID: "a", CustomerID: "c1", DateCreated: created,
+			Delivery: models.Delivery{Name: "Alice", Phone: "+79990000000", Email: "alice@example.com", City: "Moscow"},
+			Payment:  models.Payment{Currency: "RUB", Amount: 100},
+			Items:    []models.Item{{ChrtID: 1, Name: "Mascaras", Price: 100, TotalPrice: 100}},
+		},
+		{OrderUID: "b", CustomerID: "c2", DateCreated: created.Add(time.Hour), Payment: models.Payment{Currency: "USD"}},
+	}
+}
+
+func TestSaveRead(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		kr   *keyring.Keyring
+	}{
+		{"plain", nil},
+		{"encrypted", testKeyring(t)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := testOrders()
+			j := testJob(t, tt.kr, orders...)
+			if err := j.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			h, got, err := j.read()
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if h.Orders != len(orders) || time.Since(h.TakenAt) > time.Minute {
+				t.Fatalf("header = %+v, want %d orders taken just now", h, len(orders))
+			}
+			if encrypted := h.KeyID != ""; encrypted != (tt.kr != nil) {
+				t.Fatalf("header key id = %q, encrypted snapshot expected: %v", h.KeyID, tt.kr != nil)
+			}
+			byUID := make(map[string]models.Order, len(got))
+			for _, o := range got {
+				byUID[o.OrderUID] = o
+			}
+			for _, want := range orders {
+				if !reflect.DeepEqual(byUID[want.OrderUID], want) {
+					t.Fatalf("order %s = %+v, want %+v", want.OrderUID, byUID[want.OrderUID], want)
+				}
+			}
+
+			// Персональные данные не лежат на диске открыто, если снимок шифруется
+			data, err := os.ReadFile(j.cfg.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.kr != nil && bytes.Contains(data, []byte("alice@example.com")) {
+				t.Fatal("encrypted snapshot contains plaintext email")
+			}
+		})
+	}
+}
+
+func TestSaveSkipsColdCache(t *testing.T) {
+	j := testJob(t, nil, testOrders()...)
+	j.srv.Cache = cache.New(config.Cache{})
+	if err := j.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(j.cfg.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("snapshot of a cold cache saved: %v", err)
+	}
+}
+
+// Ошибки ниже обнаруживаются до догонки по базе, поэтому репозиторий не нужен
+func TestRestoreRejects(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		j := testJob(t, nil)
+		if _, err := j.Restore(context.Background()); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("Restore = %v, want os.ErrNotExist", err)
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		j := testJob(t, nil, testOrders()...)
+		if err := j.Save(); err != nil {
+			t.Fatal(err)
+		}
+		j.cfg.MaxAge = time.Nanosecond
+		restored := testJob(t, nil)
+		restored.cfg = j.cfg
+		if _, err := restored.Restore(context.Background()); !errors.Is(err, ErrStale) {
+			t.Fatalf("Restore = %v, want ErrStale", err)
+		}
+		if n := restored.srv.Cache.Len(); n != 0 {
+			t.Fatalf("stale snapshot loaded %d orders into cache", n)
+		}
+	})
+
+	t.Run("encrypted without keyring", func(t *testing.T) {
+		j := testJob(t, testKeyring(t), testOrders()...)
+		if err := j.Save(); err != nil {
+			t.Fatal(err)
+		}
+		restored := testJob(t, nil)
+		restored.cfg = j.cfg
+		if _, err := restored.Restore(context.Background()); !errors.Is(err, ErrNoKeyring) {
+			t.Fatalf("Restore = %v, want ErrNoKeyring", err)
+		}
+	})
+
+	t.Run("other keyring", func(t *testing.T) {
+		j := testJob(t, testKeyring(t), testOrders()...)
+		if err := j.Save(); err != nil {
+			t.Fatal(err)
+		}
+		restored := testJob(t, testKeyring(t))
+		restored.cfg = j.cfg
+		if _, err := restored.Restore(context.Background()); err == nil {
+			t.Fatal("Restore with a different keyring succeeded")
+		}
+	})
+
+	corrupt := map[string]func([]byte) []byte{
+		"not a snapshot": func([]byte) []byte { return []byte("hello") },
+		"empty":          func([]byte) []byte { return nil },
+		"truncated":      func(b []byte) []byte { return b[:len(b)/2] },
+		"flipped byte": func(b []byte) []byte {
+			b = bytes.Clone(b)
+			b[len(b)-10] ^= 0xff
+			return b
+		},
+	}
+	for name, damage := range corrupt {
+		for _, encrypted := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s encrypted=%v", name, encrypted), func(t *testing.T) {
+				var kr *keyring.Keyring
+				if encrypted {
+					kr = testKeyring(t)
+				}
+				j := testJob(t, kr, testOrders()...)
+				if err := j.Save(); err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(j.cfg.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(j.cfg.Path, damage(data), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if _, err = j.Restore(context.Background()); !errors.Is(err, ErrInvalid) {
+					t.Fatalf("Restore = %v, want ErrInvalid", err)
+				}
+			})
+		}
+	}
+}